@@ -0,0 +1,256 @@
+// Package backup archives all kv pairs of a store, and restores store from archive.
+//
+// An archive is composed of a header, records of kv pairs, and a trailer:
+//
+//	header:  magic | version (uint32)
+//	record:  key length (uvarint, > 0) | key | value length (uvarint) | value
+//	trailer: 0 (uvarint) | record count (uint64) | sha256 of all preceding bytes
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/kv"
+)
+
+const (
+	magic = "solidb-backup"
+	// Version version of archive format
+	Version = 1
+
+	maxKeyLen   = 4096
+	maxValueLen = 16 << 20
+	// count of pairs written in one batch on restore
+	restoreBatchLen = 1000
+)
+
+// Result summary of archived or restored pairs
+type Result struct {
+	PairCount int
+	BlobCount int
+	// CorruptCount count of corrupt blobs archived as quarantined, only counted by Write
+	CorruptCount int
+}
+
+type writer struct {
+	w   *bufio.Writer
+	h   hash.Hash
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (w *writer) write(data []byte) error {
+	w.h.Write(data)
+	_, err := w.w.Write(data)
+	return err
+}
+
+func (w *writer) writeUvarint(v uint64) error {
+	n := binary.PutUvarint(w.buf[:], v)
+	return w.write(w.buf[:n])
+}
+
+// Write write all kv pairs in view into w as archive.
+// Pass a snapshot to get a consistent archive while the store is being written.
+// Corrupt blobs are archived as quarantined, to be repaired from replicas by scrubber after restored.
+func Write(w io.Writer, view kv.View) (*Result, error) {
+	aw := &writer{w: bufio.NewWriter(w), h: sha256.New()}
+	var header [len(magic) + 4]byte
+	copy(header[:], magic)
+	binary.BigEndian.PutUint32(header[len(magic):], Version)
+	if err := aw.write(header[:]); err != nil {
+		return nil, errors.Wrap(err, "backup")
+	}
+
+	var result Result
+	iter := view.NewIterator(kv.NewRange(nil, nil))
+	defer iter.Release()
+	for iter.Next() {
+		// read value by key rather than by iterator, whose error on an unreadable value stops iteration
+		key := append([]byte(nil), iter.Key()...)
+		value, err := view.Get(key)
+		if err != nil && !kv.IsCorrupt(err) {
+			return nil, errors.Wrap(err, "backup")
+		}
+		if err == nil && value.V == nil {
+			// deleted since iterated, if view is not a snapshot
+			continue
+		}
+		isBlob := false
+		if err == nil {
+			isBlob, err = blobio.CheckStoredBlob(key, value.V)
+		} else {
+			// unreadable value is quarantined with empty content
+			value = &kv.OptValue{V: []byte{}}
+		}
+		if err != nil {
+			if key, err = blobio.CorruptMarkStoreKey(key); err != nil {
+				return nil, errors.Wrap(err, "backup")
+			}
+			isBlob = false
+			result.CorruptCount++
+		}
+		if err := aw.writeUvarint(uint64(len(key))); err != nil {
+			return nil, errors.Wrap(err, "backup")
+		}
+		if err := aw.write(key); err != nil {
+			return nil, errors.Wrap(err, "backup")
+		}
+		if err := aw.writeUvarint(uint64(len(value.V))); err != nil {
+			return nil, errors.Wrap(err, "backup")
+		}
+		if err := aw.write(value.V); err != nil {
+			return nil, errors.Wrap(err, "backup")
+		}
+		result.PairCount++
+		if isBlob {
+			result.BlobCount++
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, errors.Wrap(err, "backup")
+	}
+
+	var trailer [8]byte
+	binary.BigEndian.PutUint64(trailer[:], uint64(result.PairCount))
+	if err := aw.writeUvarint(0); err != nil {
+		return nil, errors.Wrap(err, "backup")
+	}
+	if err := aw.write(trailer[:]); err != nil {
+		return nil, errors.Wrap(err, "backup")
+	}
+	if _, err := aw.w.Write(aw.h.Sum(nil)); err != nil {
+		return nil, errors.Wrap(err, "backup")
+	}
+	return &result, errors.Wrap(aw.w.Flush(), "backup")
+}
+
+type reader struct {
+	r *bufio.Reader
+	h hash.Hash
+}
+
+func (r *reader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.h.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (r *reader) read(n uint64) ([]byte, error) {
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, err
+	}
+	r.h.Write(data)
+	return data, nil
+}
+
+func (r *reader) readBytes(max uint64) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > max {
+		return nil, errors.New("record too long")
+	}
+	return r.read(n)
+}
+
+// Restore write kv pairs in archive into store. Every blob is verified by its key,
+// and the archive is verified by the trailer.
+// Pairs may have been written into store when error returned, so store should be discarded.
+func Restore(r io.Reader, store kv.Store) (*Result, error) {
+	batch := store.NewBatch()
+	result, err := read(r, func(key, value []byte) error {
+		batch.Put(key, value)
+		if batch.Len() >= restoreBatchLen {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "restore")
+	}
+	if err := batch.Write(); err != nil {
+		return nil, errors.Wrap(err, "restore")
+	}
+	return result, nil
+}
+
+// Verify verify archive like Restore, without writing pairs.
+func Verify(r io.Reader) (*Result, error) {
+	result, err := read(r, func(key, value []byte) error { return nil })
+	if err != nil {
+		return nil, errors.Wrap(err, "verify")
+	}
+	return result, nil
+}
+
+// read read and verify archive, with each kv pair passed to put.
+func read(r io.Reader, put func(key, value []byte) error) (*Result, error) {
+	ar := &reader{r: bufio.NewReader(r), h: sha256.New()}
+	header, err := ar.read(uint64(len(magic) + 4))
+	if err != nil {
+		return nil, errors.Wrap(err, "read header")
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, errors.New("not a backup archive")
+	}
+	if v := binary.BigEndian.Uint32(header[len(magic):]); v != Version {
+		return nil, errors.Errorf("unsupported archive version %d", v)
+	}
+
+	var result Result
+	for {
+		key, err := ar.readBytes(maxKeyLen)
+		if err != nil {
+			return nil, errors.Wrap(err, "read record")
+		}
+		if len(key) == 0 {
+			break
+		}
+		value, err := ar.readBytes(maxValueLen)
+		if err != nil {
+			return nil, errors.Wrap(err, "read record")
+		}
+		isBlob, err := blobio.CheckStoredBlob(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if isBlob {
+			result.BlobCount++
+		}
+		result.PairCount++
+		if err := put(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	count, err := ar.read(8)
+	if err != nil {
+		return nil, errors.Wrap(err, "read trailer")
+	}
+	sum := ar.h.Sum(nil)
+	expected := make([]byte, len(sum))
+	if _, err := io.ReadFull(ar.r, expected); err != nil {
+		return nil, errors.Wrap(err, "read trailer")
+	}
+	if !bytes.Equal(sum, expected) {
+		return nil, errors.New("archive checksum mismatch")
+	}
+	if binary.BigEndian.Uint64(count) != uint64(result.PairCount) {
+		return nil, errors.New("record count mismatch")
+	}
+	return &result, nil
+}
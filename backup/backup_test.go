@@ -0,0 +1,137 @@
+package backup_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syndtr/goleveldb/leveldb"
+	. "github.com/vechain/solidb/backup"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/kv"
+)
+
+func TestBackup(t *testing.T) {
+	assert := assert.New(t)
+
+	db, _ := kv.NewMemStore(kv.Options{})
+	defer db.Close()
+	b := blob.New([]byte("hello world"))
+	blobio.PutBlob(db, b)
+	blobio.PinBlob(db, b.Key(), "label")
+	db.Put([]byte(".other"), []byte("v"))
+
+	var buf bytes.Buffer
+	snap, _ := db.NewSnapshot()
+	result, err := Write(&buf, snap)
+	snap.Release()
+	assert.Nil(err)
+	assert.Equal(&Result{PairCount: 3, BlobCount: 1}, result)
+	archive := buf.Bytes()
+
+	restored, _ := kv.NewMemStore(kv.Options{})
+	defer restored.Close()
+	result, err = Restore(bytes.NewReader(archive), restored)
+	assert.Nil(err)
+	assert.Equal(&Result{PairCount: 3, BlobCount: 1}, result)
+	got, _ := blobio.GetBlob(restored, b.Key())
+	assert.Equal(b.Data(), got.V.Data())
+	pinned, _ := blobio.IsBlobPinned(restored, b.Key())
+	assert.True(pinned)
+
+	// truncated
+	_, err = Verify(bytes.NewReader(archive[:len(archive)-1]))
+	assert.NotNil(err)
+
+	// tampered blob
+	// tiny data is stored uncompressed
+	tampered := bytes.Replace(archive, b.Data(), []byte("hello_world"), 1)
+	assert.NotEqual(archive, tampered)
+	_, err = Verify(bytes.NewReader(tampered))
+	assert.NotNil(err)
+}
+
+func TestBackupCorruptBlob(t *testing.T) {
+	assert := assert.New(t)
+
+	db, _ := kv.NewMemStore(kv.Options{})
+	defer db.Close()
+	good := blob.New([]byte("good"))
+	bad := blob.New([]byte("bad"))
+	blobio.PutBlob(db, good)
+	blobio.PutBlob(db, bad)
+	// stored value no longer matches the key
+	badKey := bad.Key()
+	storeKey := append([]byte("/"), badKey[:]...)
+	value, _ := db.Get(storeKey)
+	db.Put(storeKey, bytes.Replace(value.V, []byte("bad"), []byte("bed"), 1))
+
+	var buf bytes.Buffer
+	result, err := Write(&buf, db)
+	assert.Nil(err)
+	assert.Equal(&Result{PairCount: 2, BlobCount: 1, CorruptCount: 1}, result)
+
+	result, err = Verify(bytes.NewReader(buf.Bytes()))
+	assert.Nil(err)
+	assert.Equal(&Result{PairCount: 2, BlobCount: 1}, result)
+
+	restored, _ := kv.NewMemStore(kv.Options{})
+	defer restored.Close()
+	_, err = Restore(bytes.NewReader(buf.Bytes()), restored)
+	assert.Nil(err)
+	got, _ := blobio.GetBlob(restored, good.Key())
+	assert.Equal(good.Data(), got.V.Data())
+	got, _ = blobio.GetBlob(restored, bad.Key())
+	assert.Nil(got.V)
+	mark, _ := blobio.GetBlobMark(restored, bad.Key(), blobio.CorruptBlobMark)
+	assert.NotNil(mark.V, "quarantined")
+}
+
+func TestBackupUnreadableBlob(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir(os.TempDir(), "db")
+	defer os.RemoveAll(dir)
+
+	options := kv.Options{Encryption: &kv.EncryptionOptions{Key: bytes.Repeat([]byte{1}, 32)}}
+	db, err := kv.NewStore(dir, options)
+	assert.Nil(err)
+	good := blob.New([]byte("good"))
+	bad := blob.New([]byte("bad"))
+	blobio.PutBlob(db, good)
+	blobio.PutBlob(db, bad)
+	db.Close()
+
+	// tamper the sealed value, so that it can't be decrypted
+	ldb, err := leveldb.OpenFile(dir, nil)
+	assert.Nil(err)
+	badKey := bad.Key()
+	storeKey := append([]byte("/"), badKey[:]...)
+	sealed, err := ldb.Get(storeKey, nil)
+	assert.Nil(err)
+	sealed[len(sealed)-1] ^= 1
+	assert.Nil(ldb.Put(storeKey, sealed, nil))
+	ldb.Close()
+
+	db, err = kv.NewStore(dir, options)
+	assert.Nil(err)
+	defer db.Close()
+	var buf bytes.Buffer
+	result, err := Write(&buf, db)
+	assert.Nil(err)
+	assert.Equal(1, result.BlobCount)
+	assert.Equal(1, result.CorruptCount)
+
+	restored, _ := kv.NewMemStore(kv.Options{})
+	defer restored.Close()
+	_, err = Restore(bytes.NewReader(buf.Bytes()), restored)
+	assert.Nil(err)
+	got, _ := blobio.GetBlob(restored, good.Key())
+	assert.Equal(good.Data(), got.V.Data())
+	got, _ = blobio.GetBlob(restored, bad.Key())
+	assert.Nil(got.V)
+	mark, _ := blobio.GetBlobMark(restored, bad.Key(), blobio.CorruptBlobMark)
+	assert.NotNil(mark.V, "quarantined")
+}
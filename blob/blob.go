@@ -1,8 +1,19 @@
 // Package blob defines content-addressable type to contain arbitrary data.
 package blob
 
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/crypto"
+)
+
 const DataLenHardLimit = 64 * 1024
 
+// ErrDataTooLarge returned when data length exceeds DataLenHardLimit.
+var ErrDataTooLarge = errors.New("data length exceeds limit")
+
 // Blob data type stored in solidb
 type Blob struct {
 	data      []byte
@@ -14,6 +25,24 @@ func New(data []byte) *Blob {
 	return &Blob{data: data}
 }
 
+// Read read blob data from reader until EOF.
+// The key is computed while data arrives, and ErrDataTooLarge returned
+// once DataLenHardLimit is passed.
+func Read(reader io.Reader) (*Blob, error) {
+	hasher := crypto.NewHasher()
+	limited := io.LimitReader(reader, DataLenHardLimit+1)
+	data, err := ioutil.ReadAll(io.TeeReader(limited, hasher))
+	if err != nil {
+		return nil, errors.Wrap(err, "read blob")
+	}
+	if len(data) > DataLenHardLimit {
+		return nil, ErrDataTooLarge
+	}
+	var key Key
+	copy(key[:], hasher.Sum(nil))
+	return &Blob{data: data, cachedKey: &key}, nil
+}
+
 // Data get blob data
 func (blob *Blob) Data() []byte {
 	return blob.data
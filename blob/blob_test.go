@@ -1,12 +1,27 @@
 package blob_test
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	. "github.com/vechain/solidb/blob"
 )
 
 func TestBlob(t *testing.T) {
 	assert := assert.New(t)
 	_ = assert
 }
+
+func TestRead(t *testing.T) {
+	assert := assert.New(t)
+
+	data := []byte("hello world")
+	b, err := Read(bytes.NewReader(data))
+	assert.Nil(err)
+	assert.Equal(data, b.Data())
+	assert.Equal(KeyOfData(data), b.Key())
+
+	_, err = Read(bytes.NewReader(make([]byte, DataLenHardLimit+1)))
+	assert.Equal(ErrDataTooLarge, err)
+}
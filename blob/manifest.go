@@ -0,0 +1,160 @@
+package blob
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// manifest layout:
+// magic(16) | level(1) | chunk size(4) | fanout(4) | size(8) | key count(4) | keys
+var manifestMagic = []byte("\xffSOLIDB-MANIFEST")
+
+const manifestHeaderLen = 16 + 1 + 4 + 4 + 8 + 4
+
+// ManifestMaxFanout max count of keys a manifest can hold
+const ManifestMaxFanout = (DataLenHardLimit - manifestHeaderLen) / KeyLength
+
+// Manifest describes a large object which is split into chunks.
+// Chunks and manifests are stored as normal blobs.
+type Manifest struct {
+	// Level 0 means keys refer to data chunks, otherwise keys refer to manifests with level-1.
+	Level int
+	// ChunkSize size of each data chunk, except the last one.
+	ChunkSize int
+	// Fanout max count of keys held by each manifest.
+	Fanout int
+	// Size total size of data the manifest covers.
+	Size uint64
+	// Keys keys of chunks or sub-manifests.
+	Keys []Key
+}
+
+// IsManifest returns whether the data looks like a manifest.
+func IsManifest(data []byte) bool {
+	return bytes.HasPrefix(data, manifestMagic)
+}
+
+// ParseManifest decode manifest from data.
+func ParseManifest(data []byte) (*Manifest, error) {
+	if !IsManifest(data) || len(data) < manifestHeaderLen {
+		return nil, errors.New("parse manifest: not a manifest")
+	}
+	h := data[len(manifestMagic):manifestHeaderLen]
+	m := Manifest{
+		Level:     int(h[0]),
+		ChunkSize: int(binary.BigEndian.Uint32(h[1:])),
+		Fanout:    int(binary.BigEndian.Uint32(h[5:])),
+		Size:      binary.BigEndian.Uint64(h[9:]),
+	}
+	count := int(binary.BigEndian.Uint32(h[17:]))
+
+	body := data[manifestHeaderLen:]
+	if len(body) != count*KeyLength {
+		return nil, errors.New("parse manifest: invalid key count")
+	}
+	if m.ChunkSize <= 0 || m.Fanout <= 0 || count > m.Fanout {
+		return nil, errors.New("parse manifest: invalid header")
+	}
+	m.Keys = make([]Key, count)
+	for i := range m.Keys {
+		copy(m.Keys[i][:], body[i*KeyLength:])
+	}
+	if !m.sizeConsistent() {
+		return nil, errors.New("parse manifest: size inconsistent with keys")
+	}
+	return &m, nil
+}
+
+// sizeConsistent returns whether size is filled by the keys, each covering a full child span except the last one.
+func (m *Manifest) sizeConsistent() bool {
+	count := uint64(len(m.Keys))
+	if count == 0 {
+		return false
+	}
+	span := uint64(m.ChunkSize)
+	for i := 0; i < m.Level; i++ {
+		hi, lo := bits.Mul64(span, uint64(m.Fanout))
+		if hi != 0 {
+			return false
+		}
+		span = lo
+	}
+	// (count-1)*span < size <= count*span
+	if hi, lower := bits.Mul64(span, count-1); hi != 0 || m.Size <= lower {
+		return false
+	}
+	if hi, upper := bits.Mul64(span, count); hi == 0 && m.Size > upper {
+		return false
+	}
+	return true
+}
+
+// Encode encode manifest into bytes.
+func (m *Manifest) Encode() []byte {
+	data := make([]byte, manifestHeaderLen, manifestHeaderLen+len(m.Keys)*KeyLength)
+	copy(data, manifestMagic)
+	h := data[len(manifestMagic):]
+	h[0] = byte(m.Level)
+	binary.BigEndian.PutUint32(h[1:], uint32(m.ChunkSize))
+	binary.BigEndian.PutUint32(h[5:], uint32(m.Fanout))
+	binary.BigEndian.PutUint64(h[9:], m.Size)
+	binary.BigEndian.PutUint32(h[17:], uint32(len(m.Keys)))
+	for _, key := range m.Keys {
+		data = append(data, key[:]...)
+	}
+	return data
+}
+
+// ChildSpan returns count of bytes covered by each key, except the last one.
+func (m *Manifest) ChildSpan() uint64 {
+	span := uint64(m.ChunkSize)
+	for i := 0; i < m.Level; i++ {
+		span *= uint64(m.Fanout)
+	}
+	return span
+}
+
+// BuildManifests build a tree of manifests for chunk keys.
+// All chunks should be chunkSize long except the last one, and size is total size of chunks.
+// Manifests are returned in children-first order, so the last one is root.
+func BuildManifests(chunkKeys []Key, chunkSize int, size uint64) []*Blob {
+	var (
+		blobs = []*Blob{}
+		keys  = chunkKeys
+		level = 0
+		span  = uint64(chunkSize)
+	)
+	for {
+		var parents []Key
+		groupSpan := span * ManifestMaxFanout
+		for i := 0; i < len(keys); i += ManifestMaxFanout {
+			end := i + ManifestMaxFanout
+			if end > len(keys) {
+				end = len(keys)
+			}
+			offset := uint64(i) * span
+			m := Manifest{
+				Level:     level,
+				ChunkSize: chunkSize,
+				Fanout:    ManifestMaxFanout,
+				Size:      size - offset,
+				Keys:      keys[i:end],
+			}
+			if m.Size > groupSpan {
+				m.Size = groupSpan
+			}
+			blob := New(m.Encode())
+			blobs = append(blobs, blob)
+			parents = append(parents, blob.Key())
+		}
+		if len(parents) <= 1 {
+			return blobs
+		}
+		keys = parents
+		level++
+		span = groupSpan
+	}
+}
@@ -0,0 +1,71 @@
+package blob_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/vechain/solidb/blob"
+)
+
+func TestManifest(t *testing.T) {
+	assert := assert.New(t)
+
+	m := Manifest{
+		Level:     1,
+		ChunkSize: DataLenHardLimit,
+		Fanout:    ManifestMaxFanout,
+		Size:      DataLenHardLimit*ManifestMaxFanout + 12345,
+		Keys:      []Key{KeyOfData([]byte("a")), KeyOfData([]byte("b"))},
+	}
+	data := m.Encode()
+	assert.True(IsManifest(data))
+	assert.True(len(data) <= DataLenHardLimit)
+
+	_m, err := ParseManifest(data)
+	assert.Nil(err)
+	assert.Equal(m, *_m)
+
+	_, err = ParseManifest([]byte("hello world"))
+	assert.NotNil(err)
+	_, err = ParseManifest(data[:len(data)-1])
+	assert.NotNil(err)
+
+	// size not filled by keys
+	for _, size := range []uint64{0, 12345, DataLenHardLimit*ManifestMaxFanout*2 + 1} {
+		bad := m
+		bad.Size = size
+		_, err = ParseManifest(bad.Encode())
+		assert.NotNil(err, "size %d", size)
+	}
+	bad := m
+	bad.Level = 10
+	_, err = ParseManifest(bad.Encode())
+	assert.NotNil(err, "span overflows")
+}
+
+func TestBuildManifests(t *testing.T) {
+	assert := assert.New(t)
+
+	const chunkSize = 100
+	keys := make([]Key, ManifestMaxFanout+1)
+	size := uint64(len(keys)-1)*chunkSize + 10
+
+	blobs := BuildManifests(keys, chunkSize, size)
+	// two leaves and a root
+	assert.Equal(3, len(blobs))
+
+	root, err := ParseManifest(blobs[2].Data())
+	assert.Nil(err)
+	assert.Equal(1, root.Level)
+	assert.Equal(size, root.Size)
+	assert.Equal([]Key{blobs[0].Key(), blobs[1].Key()}, root.Keys)
+	assert.Equal(uint64(chunkSize*ManifestMaxFanout), root.ChildSpan())
+
+	leaf, _ := ParseManifest(blobs[1].Data())
+	assert.Equal(0, leaf.Level)
+	assert.Equal(uint64(10), leaf.Size)
+	assert.Equal(1, len(leaf.Keys))
+
+	single := BuildManifests(keys[:1], chunkSize, 10)
+	assert.Equal(1, len(single))
+}
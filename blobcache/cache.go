@@ -0,0 +1,168 @@
+// Package blobcache in-memory LRU cache of blobs.
+// Blobs are content addressed, so cached data never changes. But a cached blob may have been deleted by GC,
+// which the cache can't observe unless told by Remove. Cache with TTL bounds how long such a blob is still served.
+package blobcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobio"
+)
+
+// estimated memory cost of an entry besides its data
+const entryOverhead = 128
+
+// Entry cached blob along with its meta
+type Entry struct {
+	Blob *blob.Blob
+	Meta *blobio.Meta
+}
+
+func (e *Entry) size() int {
+	size := entryOverhead + len(e.Blob.Data())
+	if e.Meta != nil {
+		size += len(e.Meta.ContentType) + len(e.Meta.ContentDisposition)
+		for k, v := range e.Meta.Custom {
+			size += len(k) + len(v)
+		}
+	}
+	return size
+}
+
+// Stats statistics of cache
+type Stats struct {
+	Capacity int    `json:"capacity"`
+	Size     int    `json:"size"`
+	Len      int    `json:"len"`
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+}
+
+// item element of the LRU list
+type item struct {
+	entry *Entry
+	// zero if never expires
+	expiry time.Time
+}
+
+// Cache LRU cache of blobs bounded by total size.
+// All methods are safe on nil cache, which caches nothing.
+type Cache struct {
+	capacity int
+	ttl      time.Duration
+
+	mu     sync.Mutex
+	size   int
+	ll     *list.List
+	items  map[blob.Key]*list.Element
+	hits   uint64
+	misses uint64
+}
+
+// New create a cache holding no more than capacity bytes.
+// Nil returned if capacity is not positive.
+func New(capacity int) *Cache {
+	return NewWithTTL(capacity, 0)
+}
+
+// NewWithTTL create a cache whose entries expire ttl after added, 0 ttl for never.
+// Nil returned if capacity is not positive.
+func NewWithTTL(capacity int, ttl time.Duration) *Cache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &Cache{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[blob.Key]*list.Element),
+	}
+}
+
+// Get get cached entry by blob key
+func (c *Cache) Get(key blob.Key) (*Entry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		it := elem.Value.(*item)
+		if it.expiry.IsZero() || time.Now().Before(it.expiry) {
+			c.hits++
+			c.ll.MoveToFront(elem)
+			return it.entry, true
+		}
+		c.removeElement(elem)
+	}
+	c.misses++
+	return nil, false
+}
+
+// Add add an entry, and evict least recently used ones if over capacity.
+// Entries larger than capacity are not cached.
+func (c *Cache) Add(entry *Entry) {
+	if c == nil {
+		return
+	}
+	size := entry.size()
+	if size > c.capacity {
+		return
+	}
+	key := entry.Blob.Key()
+	it := &item{entry: entry}
+	if c.ttl > 0 {
+		it.expiry = time.Now().Add(c.ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.size -= elem.Value.(*item).entry.size()
+		elem.Value = it
+		c.ll.MoveToFront(elem)
+	} else {
+		c.items[key] = c.ll.PushFront(it)
+	}
+	c.size += size
+	for c.size > c.capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Remove remove entry by blob key
+func (c *Cache) Remove(key blob.Key) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+func (c *Cache) removeElement(elem *list.Element) {
+	entry := c.ll.Remove(elem).(*item).entry
+	delete(c.items, entry.Blob.Key())
+	c.size -= entry.size()
+}
+
+// Stats returns statistics of cache
+func (c *Cache) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Capacity: c.capacity,
+		Size:     c.size,
+		Len:      c.ll.Len(),
+		Hits:     c.hits,
+		Misses:   c.misses,
+	}
+}
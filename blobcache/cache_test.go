@@ -0,0 +1,68 @@
+package blobcache_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/blob"
+	. "github.com/vechain/solidb/blobcache"
+	"github.com/vechain/solidb/blobio"
+)
+
+func TestCache(t *testing.T) {
+	assert := assert.New(t)
+
+	var nilCache *Cache
+	nilCache.Add(&Entry{Blob: blob.New([]byte("a"))})
+	_, ok := nilCache.Get(blob.KeyOfData([]byte("a")))
+	assert.False(ok)
+	assert.Nil(New(0))
+
+	c := New(1000)
+	b1 := blob.New(bytes.Repeat([]byte("1"), 300))
+	b2 := blob.New(bytes.Repeat([]byte("2"), 300))
+	b3 := blob.New(bytes.Repeat([]byte("3"), 300))
+	meta := &blobio.Meta{ContentType: "text/plain"}
+
+	c.Add(&Entry{Blob: b1, Meta: meta})
+	c.Add(&Entry{Blob: b2})
+	entry, ok := c.Get(b1.Key())
+	assert.True(ok)
+	assert.Equal(meta, entry.Meta)
+
+	// b2 is least recently used
+	c.Add(&Entry{Blob: b3})
+	_, ok = c.Get(b2.Key())
+	assert.False(ok)
+	_, ok = c.Get(b3.Key())
+	assert.True(ok)
+
+	c.Remove(b1.Key())
+	_, ok = c.Get(b1.Key())
+	assert.False(ok)
+
+	// too large to cache
+	c.Add(&Entry{Blob: blob.New(bytes.Repeat([]byte("4"), 1000))})
+
+	stats := c.Stats()
+	assert.Equal(1, stats.Len)
+	assert.Equal(uint64(2), stats.Hits)
+	assert.Equal(uint64(2), stats.Misses)
+}
+
+func TestCacheTTL(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewWithTTL(1000, 50*time.Millisecond)
+	b := blob.New([]byte("b"))
+	c.Add(&Entry{Blob: b})
+	_, ok := c.Get(b.Key())
+	assert.True(ok)
+
+	time.Sleep(100 * time.Millisecond)
+	_, ok = c.Get(b.Key())
+	assert.False(ok, "expired")
+	assert.Equal(0, c.Stats().Len)
+}
@@ -4,19 +4,14 @@ package blobio
 import (
 	"encoding/binary"
 	"io"
+	"math"
 
 	"github.com/pkg/errors"
 	"github.com/vechain/solidb/blob"
 	"github.com/vechain/solidb/kv"
 )
 
-var (
-	blobPrefix = []byte("/")
-)
-
-func makeBlobKey(blobKey blob.Key) []byte {
-	return append([]byte(blobPrefix), blobKey[:]...)
-}
+var blobTable = kv.NewTable("blob", "/")
 
 // OptBlob presents optional blob.
 type OptBlob struct {
@@ -25,28 +20,170 @@ type OptBlob struct {
 
 // GetBlob get blob from kv reader by key.
 func GetBlob(reader kv.Reader, blobKey blob.Key) (*OptBlob, error) {
-	key := makeBlobKey(blobKey)
-	value, err := reader.Get(key)
+	value, err := blobTable.Reader(reader).Get(blobKey[:])
 	if err != nil {
 		return nil, errors.Wrap(err, "get blob")
 	}
 	if value.V == nil {
 		return &OptBlob{}, nil
 	}
-	return &OptBlob{blob.New(value.V)}, nil
+	blob, err := decodeValue(blobKey, value.V)
+	if err != nil {
+		return nil, errors.Wrap(err, "get blob")
+	}
+	return &OptBlob{blob}, nil
+}
+
+// HasBlob returns whether blob exists in kv reader, without reading its data.
+func HasBlob(reader kv.Reader, blobKey blob.Key) (bool, error) {
+	has, err := blobTable.Reader(reader).Has(blobKey[:])
+	if err != nil {
+		return false, errors.Wrap(err, "has blob")
+	}
+	return has, nil
 }
 
-// PutBlob  store blob to kv writer
+// PutBlob  store blob to kv writer.
+// Blob data is encoded by the write codec.
 func PutBlob(writer kv.Writer, blob *blob.Blob) error {
-	key := makeBlobKey(blob.Key())
-	if err := writer.Put(key, blob.Data()); err != nil {
+	key := blob.Key()
+	value, err := encodeValue(blob.Data())
+	if err != nil {
+		return errors.Wrap(err, "put blob")
+	}
+	if err := blobTable.Writer(writer).Put(key[:], value); err != nil {
 		return errors.Wrap(err, "put blob")
 	}
 	return nil
 }
 
-// ReadBlob read blob from reader
-func ReadBlob(reader io.Reader) (*OptBlob, error) {
+// QuarantineBlob move stored value of a corrupt blob under CorruptBlobMark, so that it's no longer served.
+// A value that can't be read back from store (see kv.IsCorrupt) is quarantined with empty value.
+// False returned if the blob is absent or intact.
+func QuarantineBlob(store kv.Store, blobKey blob.Key) (bool, error) {
+	raw := []byte{}
+	value, err := blobTable.Reader(store).Get(blobKey[:])
+	if err != nil {
+		if !kv.IsCorrupt(err) {
+			return false, errors.Wrap(err, "quarantine blob")
+		}
+		if has, err := HasBlob(store, blobKey); err != nil || !has {
+			return false, err
+		}
+	} else {
+		if value.V == nil {
+			return false, nil
+		}
+		if _, err := decodeValue(blobKey, value.V); err == nil {
+			return false, nil
+		}
+		raw = value.V
+	}
+	batch := store.NewBatch()
+	if err := MarkBlobWithValue(batch, blobKey, CorruptBlobMark, raw); err != nil {
+		return false, errors.Wrap(err, "quarantine blob")
+	}
+	if err := DeleteBlob(batch, blobKey); err != nil {
+		return false, errors.Wrap(err, "quarantine blob")
+	}
+	if err := batch.Write(); err != nil {
+		return false, errors.Wrap(err, "quarantine blob")
+	}
+	return true, nil
+}
+
+// ReencodeLegacyBlobs re-encode blobs stored before codec introduced, by the write codec.
+// At most limit blobs are scanned, starting from the blob with key not less than from, or the first blob if from is nil.
+// Key of the blob to continue with returned, or nil if all scanned.
+// Corrupt blobs are left as is.
+func ReencodeLegacyBlobs(view kv.View, writer kv.Writer, from *blob.Key, limit int) (*blob.Key, error) {
+	iter := blobTable.View(view).NewIterator(nil)
+	defer iter.Release()
+
+	var ok bool
+	if from == nil {
+		ok = iter.First()
+	} else {
+		ok = iter.Seek(from[:])
+	}
+	for n := 0; ok; ok, n = iter.Next(), n+1 {
+		if len(iter.Key()) != blob.KeyLength {
+			return nil, errors.Wrap(errors.New("invalid key"), "reencode legacy blobs")
+		}
+		var key blob.Key
+		copy(key[:], iter.Key())
+		if n == limit {
+			return &key, nil
+		}
+		b, legacy, err := decodeValueFormat(key, iter.Value())
+		if err != nil || !legacy {
+			continue
+		}
+		if err := PutBlob(writer, b); err != nil {
+			return nil, errors.Wrap(err, "reencode legacy blobs")
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, errors.Wrap(err, "reencode legacy blobs")
+	}
+	return nil, nil
+}
+
+// CheckStoredBlob check a kv pair in store, and returns whether it's a blob.
+// The value of blob is decoded and verified by the key.
+func CheckStoredBlob(storeKey, value []byte) (bool, error) {
+	key, ok := blobTable.TrimKey(storeKey)
+	if !ok {
+		return false, nil
+	}
+	if len(key) != blob.KeyLength {
+		return true, errors.Wrap(errors.New("invalid key"), "check stored blob")
+	}
+	var blobKey blob.Key
+	copy(blobKey[:], key)
+	if _, err := decodeValue(blobKey, value); err != nil {
+		return true, errors.Wrap(err, "check stored blob")
+	}
+	return true, nil
+}
+
+// CorruptMarkStoreKey returns store key of CorruptBlobMark for the blob stored at storeKey.
+func CorruptMarkStoreKey(storeKey []byte) ([]byte, error) {
+	key, ok := blobTable.TrimKey(storeKey)
+	if !ok || len(key) != blob.KeyLength {
+		return nil, errors.New("corrupt mark store key: not a blob")
+	}
+	var blobKey blob.Key
+	copy(blobKey[:], key)
+	return markTable.Key(makeMarkKey(blobKey, CorruptBlobMark)), nil
+}
+
+// DeleteBlob delete blob by key from kv writer
+func DeleteBlob(writer kv.Writer, blobKey blob.Key) error {
+	if err := blobTable.Writer(writer).Delete(blobKey[:]); err != nil {
+		return errors.Wrap(err, "delete blob")
+	}
+	return nil
+}
+
+// length indicators to mark absent blobs in stream
+const (
+	missingIndicator     = math.MaxUint32
+	unavailableIndicator = math.MaxUint32 - 1
+)
+
+// StreamItem item read from blob stream
+type StreamItem struct {
+	Key blob.Key
+	// Blob is nil if the blob is missing or unavailable
+	Blob *blob.Blob
+	// Unavailable whether the blob failed to be read
+	Unavailable bool
+}
+
+// ReadStreamItem read blob, or mark of absent blob from reader.
+// Nil returned when reach the end of stream.
+func ReadStreamItem(reader io.Reader) (*StreamItem, error) {
 	var key blob.Key
 	// firstly read key
 	if _, err := io.ReadFull(reader, key[:]); err != nil {
@@ -54,7 +191,7 @@ func ReadBlob(reader io.Reader) (*OptBlob, error) {
 	}
 	if key == blob.EmptyKey {
 		// reach the end of stream
-		return &OptBlob{}, nil
+		return nil, nil
 	}
 
 	ind := [4]byte{}
@@ -63,6 +200,14 @@ func ReadBlob(reader io.Reader) (*OptBlob, error) {
 		return nil, errors.Wrap(err, "read blob")
 	}
 	blobLen := binary.BigEndian.Uint32(ind[:])
+	switch {
+	case blobLen == missingIndicator:
+		return &StreamItem{Key: key}, nil
+	case blobLen == unavailableIndicator:
+		return &StreamItem{Key: key, Unavailable: true}, nil
+	case blobLen > blob.DataLenHardLimit:
+		return nil, errors.Wrap(blob.ErrDataTooLarge, "read blob")
+	}
 	data := make([]byte, blobLen)
 	// finally read blob data
 	if _, err := io.ReadFull(reader, data); err != nil {
@@ -74,7 +219,22 @@ func ReadBlob(reader io.Reader) (*OptBlob, error) {
 	if blob.Key() != key {
 		return nil, errors.New("read blob: key value mismatch")
 	}
-	return &OptBlob{blob}, nil
+	return &StreamItem{Key: key, Blob: blob}, nil
+}
+
+// ReadBlob read blob from reader
+func ReadBlob(reader io.Reader) (*OptBlob, error) {
+	item, err := ReadStreamItem(reader)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return &OptBlob{}, nil
+	}
+	if item.Blob == nil {
+		return nil, errors.New("read blob: unexpected absent blob")
+	}
+	return &OptBlob{item.Blob}, nil
 }
 
 // WriteBlob write blob to writer
@@ -105,3 +265,35 @@ func EndWriteBlob(writer io.Writer) error {
 	}
 	return nil
 }
+
+func writeIndicator(writer io.Writer, key blob.Key, indicator uint32) error {
+	if _, err := writer.Write(key[:]); err != nil {
+		return err
+	}
+	ind := [4]byte{}
+	binary.BigEndian.PutUint32(ind[:], indicator)
+	_, err := writer.Write(ind[:])
+	return err
+}
+
+// WriteMissing write mark to indicate the blob is not found
+func WriteMissing(writer io.Writer, key blob.Key) error {
+	return errors.Wrap(writeIndicator(writer, key, missingIndicator), "write missing")
+}
+
+// WriteUnavailable write mark to indicate the blob failed to be read
+func WriteUnavailable(writer io.Writer, key blob.Key) error {
+	return errors.Wrap(writeIndicator(writer, key, unavailableIndicator), "write unavailable")
+}
+
+// WriteStreamItem write blob, or mark of absent blob to writer
+func WriteStreamItem(writer io.Writer, item *StreamItem) error {
+	switch {
+	case item.Blob != nil:
+		return WriteBlob(writer, item.Blob)
+	case item.Unavailable:
+		return WriteUnavailable(writer, item.Key)
+	default:
+		return WriteMissing(writer, item.Key)
+	}
+}
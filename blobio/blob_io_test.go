@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/vechain/solidb/blob"
 	. "github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/kv"
 )
 
 func TestReadWrite(t *testing.T) {
@@ -38,3 +39,77 @@ func TestReadWrite(t *testing.T) {
 	opt, _ := ReadBlob(buf)
 	assert.True(opt.V == nil)
 }
+
+func TestStreamItem(t *testing.T) {
+	assert := assert.New(t)
+
+	b := blob.New([]byte("hello world"))
+	missing := blob.KeyOfData([]byte("missing"))
+	unavailable := blob.KeyOfData([]byte("unavailable"))
+
+	buf := bytes.NewBuffer([]byte{})
+	WriteBlob(buf, b)
+	WriteMissing(buf, missing)
+	WriteUnavailable(buf, unavailable)
+	EndWriteBlob(buf)
+
+	item, _ := ReadStreamItem(buf)
+	assert.Equal(b.Data(), item.Blob.Data())
+	item, _ = ReadStreamItem(buf)
+	assert.Equal(&StreamItem{Key: missing}, item)
+	item, _ = ReadStreamItem(buf)
+	assert.Equal(&StreamItem{Key: unavailable, Unavailable: true}, item)
+	item, err := ReadStreamItem(buf)
+	assert.Nil(err)
+	assert.Nil(item)
+
+	buf.Reset()
+	WriteMissing(buf, missing)
+	_, err = ReadBlob(buf)
+	assert.NotNil(err)
+}
+
+func TestHasBlob(t *testing.T) {
+	assert := assert.New(t)
+
+	db, _ := kv.NewMemStore(kv.Options{})
+	defer db.Close()
+
+	b := blob.New([]byte("hello world"))
+	has, err := HasBlob(db, b.Key())
+	assert.Nil(err)
+	assert.False(has)
+
+	PutBlob(db, b)
+	has, err = HasBlob(db, b.Key())
+	assert.Nil(err)
+	assert.True(has)
+
+	DeleteBlob(db, b.Key())
+	has, _ = HasBlob(db, b.Key())
+	assert.False(has)
+}
+
+func TestQuarantineBlob(t *testing.T) {
+	assert := assert.New(t)
+
+	db, _ := kv.NewMemStore(kv.Options{})
+	defer db.Close()
+
+	b := blob.New([]byte("hello world"))
+	PutBlob(db, b)
+	quarantined, err := QuarantineBlob(db, b.Key())
+	assert.Nil(err)
+	assert.False(quarantined, "intact blob")
+
+	key := b.Key()
+	db.Put(append([]byte("/"), key[:]...), []byte("rotten"))
+	quarantined, err = QuarantineBlob(db, b.Key())
+	assert.Nil(err)
+	assert.True(quarantined)
+
+	has, _ := HasBlob(db, b.Key())
+	assert.False(has)
+	mark, _ := GetBlobMark(db, b.Key(), CorruptBlobMark)
+	assert.Equal([]byte("rotten"), mark.V)
+}
@@ -0,0 +1,149 @@
+package blobio
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/blob"
+)
+
+// Codec encodes blob data before stored into kv store, usually compress.
+type Codec interface {
+	// Encode encode data.
+	Encode(data []byte) ([]byte, error)
+
+	// Decode decode data, the result should not exceed blob.DataLenHardLimit.
+	Decode(data []byte) ([]byte, error)
+}
+
+// predefined codec IDs
+const (
+	CodecNone  byte = 0
+	CodecFlate byte = 1
+)
+
+type codecEntry struct {
+	name  string
+	codec Codec
+}
+
+var (
+	codecs = map[byte]codecEntry{
+		CodecNone:  {"none", noneCodec{}},
+		CodecFlate: {"flate", flateCodec{}},
+	}
+	writeCodecID = CodecFlate
+)
+
+// RegisterCodec register a codec with ID and name.
+// It should be called before any blob access.
+func RegisterCodec(id byte, name string, codec Codec) error {
+	if _, ok := codecs[id]; ok {
+		return errors.Errorf("register codec: id %d taken", id)
+	}
+	codecs[id] = codecEntry{name, codec}
+	return nil
+}
+
+// SetWriteCodec set codec, by name, used to encode blob data when putting blob.
+// Blobs encoded by any registered codec can always be read.
+func SetWriteCodec(name string) error {
+	for id, entry := range codecs {
+		if entry.name == name {
+			writeCodecID = id
+			return nil
+		}
+	}
+	return errors.Errorf("set write codec: unknown codec %s", name)
+}
+
+// encodeValue encode blob data into the value stored in kv store.
+// The value is prefixed with codec ID.
+func encodeValue(data []byte) ([]byte, error) {
+	id := writeCodecID
+	enc, err := codecs[id].codec.Encode(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode value")
+	}
+	if id != CodecNone && len(enc) >= len(data) {
+		// incompressible
+		id, enc = CodecNone, data
+	}
+	return append([]byte{id}, enc...), nil
+}
+
+// decodeValue decode value stored in kv store into blob, and verify it by the key.
+func decodeValue(key blob.Key, value []byte) (*blob.Blob, error) {
+	b, _, err := decodeValueFormat(key, value)
+	return b, err
+}
+
+// decodeValueFormat decode value like decodeValue, and tells whether the value is stored before codec introduced.
+func decodeValueFormat(key blob.Key, value []byte) (*blob.Blob, bool, error) {
+	if len(value) > 0 {
+		if entry, ok := codecs[value[0]]; ok {
+			if data, err := entry.codec.Decode(value[1:]); err == nil {
+				b := blob.New(data)
+				if b.Key() == key {
+					return b, false, nil
+				}
+			}
+		}
+	}
+	// value stored before codec introduced
+	b := blob.New(value)
+	if b.Key() == key {
+		return b, true, nil
+	}
+	return nil, false, errors.New("decode value: key and value mismatch")
+}
+
+type noneCodec struct{}
+
+func (noneCodec) Encode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (noneCodec) Decode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+var flateWriterPool = sync.Pool{
+	New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	},
+}
+
+type flateCodec struct{}
+
+func (flateCodec) Encode(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := flateWriterPool.Get().(*flate.Writer)
+	defer flateWriterPool.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (flateCodec) Decode(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	dec, err := ioutil.ReadAll(io.LimitReader(r, blob.DataLenHardLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(dec) > blob.DataLenHardLimit {
+		return nil, blob.ErrDataTooLarge
+	}
+	return dec, nil
+}
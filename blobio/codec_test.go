@@ -0,0 +1,44 @@
+package blobio_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/blob"
+	. "github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/kv"
+)
+
+func TestCodec(t *testing.T) {
+	assert := assert.New(t)
+
+	db, _ := kv.NewMemStore(kv.Options{})
+	defer db.Close()
+
+	compressible := blob.New(bytes.Repeat([]byte(`{"hello":"world"}`), 1000))
+	assert.Nil(SetWriteCodec("flate"))
+	PutBlob(db, compressible)
+
+	assert.Nil(SetWriteCodec("none"))
+	plain := blob.New([]byte("hello world"))
+	PutBlob(db, plain)
+
+	// value stored before codec introduced
+	legacy := blob.New([]byte("legacy"))
+	legacyKey := legacy.Key()
+	db.Put(append([]byte("/"), legacyKey[:]...), legacy.Data())
+
+	for _, b := range []*blob.Blob{compressible, plain, legacy} {
+		opt, err := GetBlob(db, b.Key())
+		assert.Nil(err)
+		assert.Equal(b.Data(), opt.V.Data())
+	}
+
+	key := compressible.Key()
+	value, _ := db.Get(append([]byte("/"), key[:]...))
+	assert.True(len(value.V) < len(compressible.Data()))
+
+	assert.NotNil(SetWriteCodec("unknown"))
+	SetWriteCodec("flate")
+}
@@ -1,9 +1,6 @@
 package blobio
 
 import (
-	"bytes"
-	"encoding/hex"
-
 	"github.com/pkg/errors"
 	"github.com/vechain/solidb/blob"
 	"github.com/vechain/solidb/kv"
@@ -14,15 +11,15 @@ type BlobIterator struct {
 	iter kv.Iterator
 }
 
-// NewBlobIterator create blob iterator
-func NewBlobIterator(store kv.Store, blobKeyHexPrefix string) (*BlobIterator, error) {
-	hexPrefix := hex.EncodeToString(blobPrefix) + blobKeyHexPrefix
-	rng, err := kv.NewRangeWithHexPrefix(hexPrefix)
+// NewBlobIterator create blob iterator.
+// Iterating on a snapshot gives a consistent state unaffected by concurrent writes.
+func NewBlobIterator(view kv.View, blobKeyHexPrefix string) (*BlobIterator, error) {
+	rng, err := kv.NewRangeWithHexPrefix(blobKeyHexPrefix)
 	if err != nil {
 		return nil, errors.Wrap(err, "new blob iterator")
 	}
 	return &BlobIterator{
-		iter: store.NewIterator(rng),
+		iter: blobTable.View(view).NewIterator(rng),
 	}, nil
 }
 
@@ -31,6 +28,11 @@ func (bi *BlobIterator) Next() bool {
 	return bi.iter.Next()
 }
 
+// Seek move iterator to the first blob whose key is not less than the given key
+func (bi *BlobIterator) Seek(blobKey blob.Key) bool {
+	return bi.iter.Seek(blobKey[:])
+}
+
 // Release release resource alloced for iterator
 func (bi *BlobIterator) Release() {
 	bi.iter.Release()
@@ -41,11 +43,26 @@ func (bi *BlobIterator) Error() error {
 	return errors.Wrap(bi.iter.Error(), "blob iterator")
 }
 
-// Blob returns current blob
+// Key returns key of current blob, without decoding value
+func (bi *BlobIterator) Key() (*blob.Key, error) {
+	storeKey := bi.iter.Key()
+	if len(storeKey) != blob.KeyLength {
+		return nil, errors.Wrap(errors.New("invalid key"), "blob iterator")
+	}
+	var blobKey blob.Key
+	copy(blobKey[:], storeKey)
+	return &blobKey, nil
+}
+
+// Blob returns current blob, decoded and verified
 func (bi *BlobIterator) Blob() (*blob.Blob, error) {
-	blob := blob.New(bi.iter.Value())
-	if !bytes.Equal(makeBlobKey(blob.Key()), bi.iter.Key()) {
-		return nil, errors.Wrap(errors.New("key and value mismatch"), "blob iterator")
+	blobKey, err := bi.Key()
+	if err != nil {
+		return nil, err
+	}
+	blob, err := decodeValue(*blobKey, bi.iter.Value())
+	if err != nil {
+		return nil, errors.Wrap(err, "blob iterator")
 	}
 	return blob, nil
 }
@@ -9,42 +9,64 @@ import (
 const (
 	// FaultBlobMark mark indicates that a blob needs to be resent
 	FaultBlobMark = "fault"
-	markPrefix    = ".marks/"
+	// GCReachedMark mark indicates that a blob is reachable from pins, the value is GC epoch
+	GCReachedMark = "gc-reached"
+	// GCCandidateMark mark indicates that a blob is unreachable, the value is time first found
+	GCCandidateMark = "gc-candidate"
+	// CorruptBlobMark mark indicates that a stored blob is corrupt, the value is the quarantined raw value,
+	// or empty if unreadable
+	CorruptBlobMark = "corrupt"
 )
 
+var markTable = kv.NewTable("mark", ".marks/")
+
 func makeMarkKey(blobKey blob.Key, mark string) []byte {
-	return append([]byte(markPrefix+mark), blobKey[:]...)
+	return append([]byte(mark), blobKey[:]...)
 }
 
 func extractBlobKey(markKey []byte, mark string) (*blob.Key, error) {
-	prefix := markPrefix + mark
-	if len(markKey)-len(prefix) != blob.KeyLength {
+	if len(markKey)-len(mark) != blob.KeyLength {
 		return nil, errors.New("invalid blob mark")
 	}
 	var blobKey blob.Key
-	copy(blobKey[:], markKey[len(prefix):])
+	copy(blobKey[:], markKey[len(mark):])
 	return &blobKey, nil
 }
 
 // MarkBlob mark a blob
-func MarkBlob(store kv.Store, blobKey blob.Key, mark string) error {
+func MarkBlob(writer kv.Writer, blobKey blob.Key, mark string) error {
+	key := makeMarkKey(blobKey, mark)
+	return errors.Wrap(markTable.Writer(writer).Put(key, []byte{}), "mark blob")
+}
+
+// MarkBlobWithValue mark a blob with value attached
+func MarkBlobWithValue(writer kv.Writer, blobKey blob.Key, mark string, value []byte) error {
 	key := makeMarkKey(blobKey, mark)
-	return errors.Wrap(store.Put(key, []byte{}), "mark blob")
+	return errors.Wrap(markTable.Writer(writer).Put(key, value), "mark blob")
+}
+
+// GetBlobMark get value of mark to a blob. Nil value returned if not marked.
+func GetBlobMark(reader kv.Reader, blobKey blob.Key, mark string) (*kv.OptValue, error) {
+	key := makeMarkKey(blobKey, mark)
+	value, err := markTable.Reader(reader).Get(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "get blob mark")
+	}
+	return value, nil
 }
 
 // UnmarkBlob delete mark to a blob
-func UnmarkBlob(store kv.Store, blobKey blob.Key, mark string) error {
+func UnmarkBlob(writer kv.Writer, blobKey blob.Key, mark string) error {
 	key := makeMarkKey(blobKey, mark)
-	return errors.Wrap(store.Delete(key), "unmark blob")
+	return errors.Wrap(markTable.Writer(writer).Delete(key), "unmark blob")
 }
 
 // NewMarkIterator returns an iterator for all blob keys marked with mark
-func NewMarkIterator(store kv.Store, mark string) *MarkIterator {
-	prefix := markPrefix + mark
-	rng := kv.NewRangeWithBytesPrefix([]byte(prefix))
+func NewMarkIterator(view kv.View, mark string) *MarkIterator {
+	rng := kv.NewRangeWithBytesPrefix([]byte(mark))
 	return &MarkIterator{
 		mark: mark,
-		it:   store.NewIterator(rng),
+		it:   markTable.View(view).NewIterator(rng),
 	}
 }
 
@@ -78,3 +100,8 @@ func (mi *MarkIterator) BlobKey() (*blob.Key, error) {
 	}
 	return blobKey, nil
 }
+
+// Value returns value attached to the mark
+func (mi *MarkIterator) Value() []byte {
+	return mi.it.Value()
+}
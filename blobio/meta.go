@@ -0,0 +1,135 @@
+package blobio
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/kv"
+)
+
+const (
+	// MaxMetaLen max length of encoded meta
+	MaxMetaLen = 2048
+)
+
+// Meta metadata of blob, which is stored alongside but not part of the blob
+type Meta struct {
+	ContentType        string            `json:"contentType,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	Custom             map[string]string `json:"custom,omitempty"`
+}
+
+// IsEmpty returns whether meta has nothing set
+func (m *Meta) IsEmpty() bool {
+	return m.ContentType == "" && m.ContentDisposition == "" && len(m.Custom) == 0
+}
+
+// Encode encode meta into bytes
+func (m *Meta) Encode() ([]byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode meta")
+	}
+	if len(data) > MaxMetaLen {
+		return nil, errors.New("encode meta: too large")
+	}
+	return data, nil
+}
+
+// DecodeMeta decode meta from bytes
+func DecodeMeta(data []byte) (*Meta, error) {
+	if len(data) > MaxMetaLen {
+		return nil, errors.New("decode meta: too large")
+	}
+	var m Meta
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, errors.Wrap(err, "decode meta")
+	}
+	return &m, nil
+}
+
+// KeyedMeta meta with key of blob it belongs to
+type KeyedMeta struct {
+	Key  blob.Key `json:"key"`
+	Meta *Meta    `json:"meta"`
+}
+
+var metaTable = kv.NewTable("meta", ".meta/")
+
+// PutBlobMeta store meta of a blob
+func PutBlobMeta(writer kv.Writer, blobKey blob.Key, meta *Meta) error {
+	data, err := meta.Encode()
+	if err != nil {
+		return errors.Wrap(err, "put blob meta")
+	}
+	return errors.Wrap(metaTable.Writer(writer).Put(blobKey[:], data), "put blob meta")
+}
+
+// GetBlobMeta get meta of a blob. Nil returned if absent.
+func GetBlobMeta(reader kv.Reader, blobKey blob.Key) (*Meta, error) {
+	value, err := metaTable.Reader(reader).Get(blobKey[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "get blob meta")
+	}
+	if value.V == nil {
+		return nil, nil
+	}
+	meta, err := DecodeMeta(value.V)
+	if err != nil {
+		return nil, errors.Wrap(err, "get blob meta")
+	}
+	return meta, nil
+}
+
+// DeleteBlobMeta delete meta of a blob
+func DeleteBlobMeta(writer kv.Writer, blobKey blob.Key) error {
+	return errors.Wrap(metaTable.Writer(writer).Delete(blobKey[:]), "delete blob meta")
+}
+
+// NewMetaIterator create meta iterator for blobs with key prefix
+func NewMetaIterator(view kv.View, blobKeyHexPrefix string) (*MetaIterator, error) {
+	rng, err := kv.NewRangeWithHexPrefix(blobKeyHexPrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "new meta iterator")
+	}
+	return &MetaIterator{
+		iter: metaTable.View(view).NewIterator(rng),
+	}, nil
+}
+
+// MetaIterator iterates blob metas in kv store
+type MetaIterator struct {
+	iter kv.Iterator
+}
+
+// Next advance iterator
+func (mi *MetaIterator) Next() bool {
+	return mi.iter.Next()
+}
+
+// Release release resource alloced for iterator
+func (mi *MetaIterator) Release() {
+	mi.iter.Release()
+}
+
+// Error returns error occurred
+func (mi *MetaIterator) Error() error {
+	return errors.Wrap(mi.iter.Error(), "meta iterator")
+}
+
+// Meta returns current meta with key of blob
+func (mi *MetaIterator) Meta() (*KeyedMeta, error) {
+	storeKey := mi.iter.Key()
+	if len(storeKey) != blob.KeyLength {
+		return nil, errors.Wrap(errors.New("invalid key"), "meta iterator")
+	}
+	meta, err := DecodeMeta(mi.iter.Value())
+	if err != nil {
+		return nil, errors.Wrap(err, "meta iterator")
+	}
+	var km KeyedMeta
+	copy(km.Key[:], storeKey)
+	km.Meta = meta
+	return &km, nil
+}
@@ -0,0 +1,45 @@
+package blobio_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/blob"
+	. "github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/kv"
+)
+
+func TestMeta(t *testing.T) {
+	assert := assert.New(t)
+
+	db, _ := kv.NewMemStore(kv.Options{})
+	defer db.Close()
+
+	key := blob.KeyOfData([]byte("hello world"))
+	meta, err := GetBlobMeta(db, key)
+	assert.Nil(err)
+	assert.Nil(meta)
+
+	expected := &Meta{
+		ContentType: "text/plain",
+		Custom:      map[string]string{"author": "foo"},
+	}
+	assert.Nil(PutBlobMeta(db, key, expected))
+	assert.NotNil(PutBlobMeta(db, key, &Meta{ContentType: strings.Repeat("x", MaxMetaLen)}))
+
+	meta, _ = GetBlobMeta(db, key)
+	assert.Equal(expected, meta)
+
+	iter, _ := NewMetaIterator(db, key.ToHex()[:3])
+	assert.True(iter.Next())
+	km, err := iter.Meta()
+	assert.Nil(err)
+	assert.Equal(&KeyedMeta{Key: key, Meta: expected}, km)
+	assert.False(iter.Next())
+	iter.Release()
+
+	assert.Nil(DeleteBlobMeta(db, key))
+	meta, _ = GetBlobMeta(db, key)
+	assert.Nil(meta)
+}
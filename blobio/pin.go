@@ -0,0 +1,105 @@
+package blobio
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/kv"
+)
+
+// MaxPinLabelLen max length of pin label
+const MaxPinLabelLen = 64
+
+var pinTable = kv.NewTable("pin", ".pins/")
+
+// Pin a labeled reference to a blob, which prevents the blob being collected
+type Pin struct {
+	Key   blob.Key `json:"key"`
+	Label string   `json:"label"`
+}
+
+func makePinKey(blobKey blob.Key, label string) []byte {
+	key := append([]byte{}, blobKey[:]...)
+	return append(key, label...)
+}
+
+func validatePinLabel(label string) error {
+	if label == "" || len(label) > MaxPinLabelLen || strings.Contains(label, "/") {
+		return errors.New("invalid pin label")
+	}
+	return nil
+}
+
+// PinBlob pin a blob with label
+func PinBlob(writer kv.Writer, blobKey blob.Key, label string) error {
+	if err := validatePinLabel(label); err != nil {
+		return errors.Wrap(err, "pin blob")
+	}
+	key := makePinKey(blobKey, label)
+	return errors.Wrap(pinTable.Writer(writer).Put(key, []byte{}), "pin blob")
+}
+
+// UnpinBlob delete pin with label to a blob
+func UnpinBlob(writer kv.Writer, blobKey blob.Key, label string) error {
+	if err := validatePinLabel(label); err != nil {
+		return errors.Wrap(err, "unpin blob")
+	}
+	key := makePinKey(blobKey, label)
+	return errors.Wrap(pinTable.Writer(writer).Delete(key), "unpin blob")
+}
+
+// IsBlobPinned returns whether the blob has any pin
+func IsBlobPinned(view kv.View, blobKey blob.Key) (bool, error) {
+	rng := kv.NewRangeWithBytesPrefix(makePinKey(blobKey, ""))
+	iter := pinTable.View(view).NewIterator(rng)
+	defer iter.Release()
+	pinned := iter.Next()
+	if err := iter.Error(); err != nil {
+		return false, errors.Wrap(err, "is blob pinned")
+	}
+	return pinned, nil
+}
+
+// NewPinIterator create pin iterator for pins to blobs with key prefix
+func NewPinIterator(view kv.View, blobKeyHexPrefix string) (*PinIterator, error) {
+	rng, err := kv.NewRangeWithHexPrefix(blobKeyHexPrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "new pin iterator")
+	}
+	return &PinIterator{
+		iter: pinTable.View(view).NewIterator(rng),
+	}, nil
+}
+
+// PinIterator iterates pins in kv store
+type PinIterator struct {
+	iter kv.Iterator
+}
+
+// Next advance iterator
+func (pi *PinIterator) Next() bool {
+	return pi.iter.Next()
+}
+
+// Release release resource alloced for iterator
+func (pi *PinIterator) Release() {
+	pi.iter.Release()
+}
+
+// Error returns error occurred
+func (pi *PinIterator) Error() error {
+	return errors.Wrap(pi.iter.Error(), "pin iterator")
+}
+
+// Pin returns current pin
+func (pi *PinIterator) Pin() (*Pin, error) {
+	storeKey := pi.iter.Key()
+	if len(storeKey) <= blob.KeyLength {
+		return nil, errors.Wrap(errors.New("invalid key"), "pin iterator")
+	}
+	var pin Pin
+	copy(pin.Key[:], storeKey)
+	pin.Label = string(storeKey[blob.KeyLength:])
+	return &pin, nil
+}
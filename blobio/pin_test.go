@@ -0,0 +1,43 @@
+package blobio_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/blob"
+	. "github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/kv"
+)
+
+func TestPin(t *testing.T) {
+	assert := assert.New(t)
+
+	db, _ := kv.NewMemStore(kv.Options{})
+	defer db.Close()
+
+	key := blob.KeyOfData([]byte("hello world"))
+	pinned, _ := IsBlobPinned(db, key)
+	assert.False(pinned)
+
+	assert.Nil(PinBlob(db, key, "l1"))
+	assert.Nil(PinBlob(db, key, "l2"))
+	assert.NotNil(PinBlob(db, key, ""))
+	assert.NotNil(PinBlob(db, key, "a/b"))
+
+	pinned, _ = IsBlobPinned(db, key)
+	assert.True(pinned)
+
+	iter, _ := NewPinIterator(db, key.ToHex()[:3])
+	var pins []Pin
+	for iter.Next() {
+		pin, _ := iter.Pin()
+		pins = append(pins, *pin)
+	}
+	iter.Release()
+	assert.Equal([]Pin{{key, "l1"}, {key, "l2"}}, pins)
+
+	UnpinBlob(db, key, "l1")
+	UnpinBlob(db, key, "l2")
+	pinned, _ = IsBlobPinned(db, key)
+	assert.False(pinned)
+}
@@ -0,0 +1,276 @@
+package broker
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/node"
+	"github.com/vechain/solidb/quorum"
+	"github.com/vechain/solidb/spec"
+	"github.com/vechain/solidb/specmgr"
+	"github.com/vechain/solidb/utils/httpx"
+)
+
+// BatchGetBlobs get blobs by keys, with one request per node, and read quorum applied per key.
+// Results are passed to emit in order of keys, with duplicated keys removed.
+func (b *Broker) BatchGetBlobs(ctx context.Context, keys []blob.Key, emit func(*blobio.StreamItem) error) error {
+	approved, err := b.specMgr.GetByTag(specmgr.TagApproved)
+	if err != nil {
+		return err
+	}
+	if approved.V == nil {
+		return errors.New("no approved spec")
+	}
+
+	type group struct {
+		entry spec.Entry
+		keys  []blob.Key
+	}
+	var (
+		groups  = make(map[string]*group)
+		votes   = make(map[blob.Key]chan quorum.Vote)
+		ordered []blob.Key
+	)
+	for _, key := range keys {
+		if _, ok := votes[key]; ok {
+			continue
+		}
+		entries := approved.V.SAT.Locate(key.ToHex())
+		votes[key] = make(chan quorum.Vote, len(entries))
+		ordered = append(ordered, key)
+		for _, entry := range entries {
+			g := groups[entry.ID]
+			if g == nil {
+				g = &group{entry: entry}
+				groups[entry.ID] = g
+			}
+			g.keys = append(g.keys, key)
+		}
+	}
+
+	for _, g := range groups {
+		g := g
+		b.run(func() {
+			b.batchGetFromNode(g.entry, g.keys, votes)
+		})
+	}
+
+	for _, key := range ordered {
+		ch := votes[key]
+		item := blobio.StreamItem{Key: key}
+		data, err := quorum.HandleRead(ctx, ch, cap(ch))
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			item.Unavailable = true
+		} else if data != nil {
+			item.Blob = data.(*result).blob
+		}
+		if err := emit(&item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchGetFromNode get blobs from a node, and send a vote for each key.
+func (b *Broker) batchGetFromNode(entry spec.Entry, keys []blob.Key, votes map[blob.Key]chan quorum.Vote) {
+	var (
+		err     error
+		pending = make(map[blob.Key]bool, len(keys))
+	)
+	for _, key := range keys {
+		pending[key] = true
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.Errorf("batch get: goroutine recovered %v", e)
+			log.Warnln(err)
+		}
+		if err == nil {
+			err = errors.New("absent in response")
+		}
+		// keys not responded are treated as errored
+		for key := range pending {
+			votes[key] <- &result{entry: &entry, err: err}
+		}
+	}()
+
+	reader, err := b.rpc(entry).BatchGetBlobs(keys)
+	if err != nil {
+		if !httpx.IsCausedByContextCanceled(err) {
+			log.Warnf("Batch get blobs from node %v: %v", entry, err)
+		}
+		return
+	}
+	defer reader.Close()
+
+	for {
+		var item *blobio.StreamItem
+		item, err = blobio.ReadStreamItem(reader)
+		if err != nil {
+			log.Warnf("Batch get blobs from node %v: %v", entry, err)
+			return
+		}
+		if item == nil {
+			return
+		}
+		if !pending[item.Key] {
+			continue
+		}
+		delete(pending, item.Key)
+
+		r := result{entry: &entry, blob: item.Blob}
+		if item.Unavailable {
+			r.err = errors.New("unavailable")
+		}
+		votes[item.Key] <- &r
+	}
+}
+
+var errManifestLike = errors.New("data looks like a manifest")
+
+// BatchPutBlobs put blobs with one request per node, and write quorum applied per key.
+// Keys written with quorum but not to all nodes are marked as fault, to be healed later.
+// Results are in order of blobs, with duplicated keys removed.
+// Data which looks like a manifest fails, since it can only be stored wrapped by PutObject.
+func (b *Broker) BatchPutBlobs(ctx context.Context, blobs []*blob.Blob) ([]node.BatchPutResult, error) {
+	approved, err := b.specMgr.GetByTag(specmgr.TagApproved)
+	if err != nil {
+		return nil, err
+	}
+	if approved.V == nil {
+		return nil, errors.New("no approved spec")
+	}
+	newest, err := b.specMgr.GetNewest()
+	if err != nil {
+		return nil, err
+	}
+	if newest.V == nil {
+		return nil, errors.New("no spec found")
+	}
+
+	type group struct {
+		entry spec.Entry
+		blobs []*blob.Blob
+		err   error
+	}
+	var (
+		groups   = make(map[string]*group)
+		seen     = make(map[blob.Key]bool)
+		rejected = make(map[blob.Key]bool)
+		ordered  []blob.Key
+	)
+	addToGroup := func(entry spec.Entry, data *blob.Blob) {
+		g := groups[entry.ID]
+		if g == nil {
+			g = &group{entry: entry}
+			groups[entry.ID] = g
+		}
+		g.blobs = append(g.blobs, data)
+	}
+	for _, data := range blobs {
+		key := data.Key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ordered = append(ordered, key)
+		if blob.IsManifest(data.Data()) {
+			rejected[key] = true
+			continue
+		}
+		for _, entry := range approved.V.SAT.Locate(key.ToHex()) {
+			addToGroup(entry, data)
+		}
+		for _, entry := range newest.V.SAT.Locate(key.ToHex()) {
+			if approved.V.SAT.FindEntry(entry.ID) == nil {
+				addToGroup(entry, data)
+			}
+		}
+	}
+
+	done := make(chan *group, len(groups))
+	for _, g := range groups {
+		g := g
+		b.run(func() {
+			defer func() {
+				if err := recover(); err != nil {
+					g.err = errors.Errorf("batch put: goroutine recovered %v", err)
+					log.Warnln(g.err)
+				}
+				done <- g
+			}()
+			if g.err = b.rpc(g.entry).BatchPutBlobs(g.blobs); g.err != nil {
+				if node.IsInsufficientStorage(g.err) {
+					log.Warnf("Batch put blobs to node %v: out of capacity, to be healed", g.entry)
+				} else if !httpx.IsCausedByContextCanceled(g.err) {
+					log.Warnf("Batch put blobs to node %v: %v", g.entry, g.err)
+				}
+			}
+		})
+	}
+	for i := 0; i < len(groups); i++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-done:
+		}
+	}
+
+	results := make([]node.BatchPutResult, 0, len(ordered))
+	faultBatch := b.store.NewBatch()
+	for _, key := range ordered {
+		if rejected[key] {
+			results = append(results, node.BatchPutResult{
+				Key:    key,
+				Status: node.BatchPutFailed,
+				Error:  errManifestLike.Error(),
+			})
+			continue
+		}
+		var (
+			entries = approved.V.SAT.Locate(key.ToHex())
+			qch     = make(chan quorum.Vote, len(entries))
+			okCount = 0
+		)
+		for _, entry := range entries {
+			entry := entry
+			g := groups[entry.ID]
+			if g.err == nil {
+				okCount++
+			}
+			qch <- &result{entry: &entry, err: g.err}
+		}
+		hasFault := okCount != len(entries)
+		for _, entry := range newest.V.SAT.Locate(key.ToHex()) {
+			if approved.V.SAT.FindEntry(entry.ID) == nil && groups[entry.ID].err != nil {
+				hasFault = true
+			}
+		}
+
+		r := node.BatchPutResult{Key: key, Status: node.BatchPutOK}
+		if err := quorum.HandleWrite(ctx, qch, len(entries)); err != nil {
+			r.Status = node.BatchPutFailed
+			r.Error = err.Error()
+			// partially replicated, let it be healed
+			hasFault = okCount > 0
+		} else if hasFault {
+			r.Status = node.BatchPutFault
+		}
+		if hasFault {
+			if err := blobio.MarkBlob(faultBatch, key, blobio.FaultBlobMark); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, r)
+	}
+	if err := faultBatch.Write(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
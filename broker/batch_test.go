@@ -0,0 +1,97 @@
+package broker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/node"
+)
+
+func TestBatchGet(t *testing.T) {
+	assert := assert.New(t)
+	c := newTestCluster(node.Options{})
+	defer c.Close()
+
+	k1 := c.put([]byte("blob 1"))
+	k2 := c.put([]byte("blob 2"))
+	missing := blob.KeyOfData([]byte("missing"))
+
+	reqBody, _ := json.Marshal(&node.BatchGetRequest{Keys: []blob.Key{k2, missing, k1, k2}})
+	resp, body := c.do(http.MethodPost, "/blobs:batchGet", nil, reqBody)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	var items []blobio.StreamItem
+	r := bytes.NewReader(body)
+	for {
+		item, err := blobio.ReadStreamItem(r)
+		assert.Nil(err)
+		if item == nil {
+			break
+		}
+		items = append(items, *item)
+	}
+	assert.Equal(0, r.Len(), "stream ended")
+	if assert.Len(items, 3, "duplicated keys removed") {
+		assert.Equal("blob 2", string(items[0].Blob.Data()))
+		assert.Equal(missing, items[1].Key)
+		assert.Nil(items[1].Blob)
+		assert.False(items[1].Unavailable)
+		assert.Equal("blob 1", string(items[2].Blob.Data()))
+	}
+
+	keys := make([]blob.Key, node.MaxBatchKeys+1)
+	reqBody, _ = json.Marshal(&node.BatchGetRequest{Keys: keys})
+	resp, _ = c.do(http.MethodPost, "/blobs:batchGet", nil, reqBody)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode, "too many keys")
+
+	resp, _ = c.do(http.MethodPost, "/blobs:batchGet", nil, []byte("{"))
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestBatchPut(t *testing.T) {
+	assert := assert.New(t)
+	c := newTestCluster(node.Options{})
+	defer c.Close()
+
+	forged := blob.Manifest{ChunkSize: 1, Fanout: 1, Size: 1 << 40, Keys: []blob.Key{{}}}
+	blobs := []*blob.Blob{
+		blob.New([]byte("blob 1")),
+		blob.New(forged.Encode()),
+		blob.New([]byte("blob 1")),
+	}
+	var buf bytes.Buffer
+	for _, b := range blobs {
+		blobio.WriteBlob(&buf, b)
+	}
+	blobio.EndWriteBlob(&buf)
+
+	resp, body := c.do(http.MethodPost, "/blobs:batchPut", nil, buf.Bytes())
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	var putResp node.BatchPutResponse
+	assert.Nil(json.Unmarshal(body, &putResp))
+	if assert.Len(putResp.Results, 2) {
+		assert.Equal(node.BatchPutResult{Key: blobs[0].Key(), Status: node.BatchPutOK}, putResp.Results[0])
+		assert.Equal(blobs[1].Key(), putResp.Results[1].Key)
+		assert.Equal(node.BatchPutFailed, putResp.Results[1].Status, "manifest-like data rejected")
+	}
+
+	resp, body = c.do(http.MethodGet, "/blobs/"+blobs[0].Key().ToHex(), nil, nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("blob 1", string(body))
+	resp, _ = c.do(http.MethodGet, "/blobs/"+blobs[1].Key().ToHex(), nil, nil)
+	assert.Equal(http.StatusNoContent, resp.StatusCode)
+
+	// manifest-like data is wrapped by single put
+	key := c.put(forged.Encode())
+	resp, body = c.do(http.MethodGet, "/blobs/"+key.ToHex(), nil, nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(forged.Encode(), body)
+
+	resp, _ = c.do(http.MethodPost, "/blobs:batchPut", nil, []byte("garbage"))
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+}
@@ -4,10 +4,12 @@ package broker
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobcache"
 	"github.com/vechain/solidb/blobio"
 	"github.com/vechain/solidb/kv"
 	"github.com/vechain/solidb/node"
@@ -21,6 +23,7 @@ import (
 type result struct {
 	entry *spec.Entry
 	blob  *blob.Blob
+	meta  *blobio.Meta
 	err   error
 }
 
@@ -29,38 +32,82 @@ func (r *result) Errored() bool {
 }
 
 func (r *result) Data() interface{} {
-	return r.blob
+	// avoid returning typed nil, which is treated as non-nil data
+	if r.blob == nil {
+		return nil
+	}
+	return r
+}
+
+// Options options of broker
+type Options struct {
+	// CacheSize max bytes of blobs cached in memory, 0 to disable
+	CacheSize int
+	// CacheTTL max duration a blob is served from cache, 0 for no limit.
+	// The broker isn't told when nodes delete blobs by GC, so a deleted blob may be served from cache until expired.
+	CacheTTL time.Duration
+	// MaxEncryptedObjectSize max bytes of object uploaded with convergent encryption,
+	// DefaultMaxEncryptedObjectSize if 0
+	MaxEncryptedObjectSize int64
 }
 
 // Broker broker is entry to access solidb
 type Broker struct {
 	store   kv.Store
 	specMgr *specmgr.SpecManager
+	signer  node.Signer
 	nodeRPC *node.RPC
+	cache   *blobcache.Cache
 	cancel  context.CancelFunc
 	wg      sync.WaitGroup
+
+	// max bytes of object uploaded with convergent encryption
+	maxEncryptedObjectSize int64
 }
 
-// New create an broker instance
-func New(store kv.Store, specMgr *specmgr.SpecManager) *Broker {
+// New create an broker instance.
+// Requests to nodes are signed by signer, which should be a node of the cluster.
+func New(store kv.Store, specMgr *specmgr.SpecManager, signer node.Signer, options Options) *Broker {
 	ctx, cancel := context.WithCancel(context.Background())
+	maxEncryptedObjectSize := options.MaxEncryptedObjectSize
+	if maxEncryptedObjectSize <= 0 {
+		maxEncryptedObjectSize = DefaultMaxEncryptedObjectSize
+	}
 	return &Broker{
 		store:   store,
 		specMgr: specMgr,
+		signer:  signer,
 		nodeRPC: node.NewRPC().WithContext(ctx),
+		cache:   blobcache.NewWithTTL(options.CacheSize, options.CacheTTL),
 		cancel:  cancel,
+
+		maxEncryptedObjectSize: maxEncryptedObjectSize,
 	}
 }
 
+// CacheStats returns statistics of blob cache, nil if cache disabled
+func (b *Broker) CacheStats() *blobcache.Stats {
+	if b.cache == nil {
+		return nil
+	}
+	stats := b.cache.Stats()
+	return &stats
+}
+
 // Shutdown stop all goroutines and wait for stopped
 func (b *Broker) Shutdown() {
 	b.cancel()
 	b.wg.Wait()
 }
 
+// rpc returns RPC to node of entry
+func (b *Broker) rpc(entry spec.Entry) *node.RPC {
+	return b.nodeRPC.WithAddr(entry.Addr).WithSigner(b.signer, entry.ID)
+}
+
 func (b *Broker) run(f func()) {
+	b.wg.Add(1)
 	go func() {
-		b.wg.Add(1)
 		defer b.wg.Done()
 		f()
 	}()
@@ -68,12 +115,21 @@ func (b *Broker) run(f func()) {
 
 // GetBlob get blob by its key
 func (b *Broker) GetBlob(ctx context.Context, key blob.Key) (*blobio.OptBlob, error) {
+	blob, _, err := b.GetBlobWithMeta(ctx, key)
+	return blob, err
+}
+
+// GetBlobWithMeta get blob along with its meta, which is nil if absent.
+func (b *Broker) GetBlobWithMeta(ctx context.Context, key blob.Key) (*blobio.OptBlob, *blobio.Meta, error) {
+	if entry, ok := b.cache.Get(key); ok {
+		return &blobio.OptBlob{V: entry.Blob}, entry.Meta, nil
+	}
 	approved, err := b.specMgr.GetByTag(specmgr.TagApproved)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if approved.V == nil {
-		return nil, errors.New("no approved spec")
+		return nil, nil, errors.New("no approved spec")
 	}
 
 	entries := approved.V.SAT.Locate(key.ToHex())
@@ -89,8 +145,8 @@ func (b *Broker) GetBlob(ctx context.Context, key blob.Key) (*blobio.OptBlob, er
 				}
 			}()
 			r := result{entry: &entry}
-			rpc := b.nodeRPC.WithAddr(entry.Addr)
-			blob, err := rpc.GetBlob(key)
+			rpc := b.rpc(entry)
+			blob, meta, err := rpc.GetBlobWithMeta(key)
 			if err != nil {
 				r.err = err
 				if !httpx.IsCausedByContextCanceled(err) {
@@ -98,6 +154,7 @@ func (b *Broker) GetBlob(ctx context.Context, key blob.Key) (*blobio.OptBlob, er
 				}
 			} else {
 				r.blob = blob.V
+				r.meta = meta
 			}
 			ch <- &r
 		})
@@ -105,16 +162,58 @@ func (b *Broker) GetBlob(ctx context.Context, key blob.Key) (*blobio.OptBlob, er
 
 	data, err := quorum.HandleRead(ctx, ch, len(entries))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if data == nil {
-		return &blobio.OptBlob{}, nil
+		return &blobio.OptBlob{}, nil, nil
 	}
-	return &blobio.OptBlob{V: data.(*blob.Blob)}, nil
+	r := data.(*result)
+	b.cache.Add(&blobcache.Entry{Blob: r.blob, Meta: r.meta})
+	return &blobio.OptBlob{V: r.blob}, r.meta, nil
 }
 
-// PutBlob store a blob
-func (b *Broker) PutBlob(ctx context.Context, blob *blob.Blob) error {
+// HasBlob returns whether blob exists, decided by read quorum of existence.
+func (b *Broker) HasBlob(ctx context.Context, key blob.Key) (bool, error) {
+	approved, err := b.specMgr.GetByTag(specmgr.TagApproved)
+	if err != nil {
+		return false, err
+	}
+	if approved.V == nil {
+		return false, errors.New("no approved spec")
+	}
+
+	entries := approved.V.SAT.Locate(key.ToHex())
+	ch := make(chan quorum.Vote, len(entries))
+	for _, entry := range entries {
+		entry := entry
+		b.run(func() {
+			defer func() {
+				if err := recover(); err != nil {
+					e := errors.Errorf("has blob: goroutine recovered %v", err)
+					log.Warnln(e)
+					ch <- &existence{err: e}
+				}
+			}()
+			var r existence
+			rpc := b.rpc(entry)
+			if r.exists, r.err = rpc.HasBlob(key); r.err != nil {
+				if !httpx.IsCausedByContextCanceled(r.err) {
+					log.Warnf("Has blob from node %v: %v", entry, r.err)
+				}
+			}
+			ch <- &r
+		})
+	}
+
+	data, err := quorum.HandleRead(ctx, ch, len(entries))
+	if err != nil {
+		return false, err
+	}
+	return data != nil, nil
+}
+
+// PutBlob store a blob, with optional meta
+func (b *Broker) PutBlob(ctx context.Context, blob *blob.Blob, meta *blobio.Meta) error {
 	key := blob.Key()
 	approved, err := b.specMgr.GetByTag(specmgr.TagApproved)
 	if err != nil {
@@ -147,10 +246,12 @@ func (b *Broker) PutBlob(ctx context.Context, blob *blob.Blob) error {
 			}()
 
 			r := result{entry: &entry}
-			rpc := b.nodeRPC.WithAddr(entry.Addr)
-			if err := rpc.PutBlob(blob); err != nil {
+			rpc := b.rpc(entry)
+			if err := rpc.PutBlob(blob, meta); err != nil {
 				r.err = err
-				if !httpx.IsCausedByContextCanceled(err) {
+				if node.IsInsufficientStorage(err) {
+					log.Warnf("Put blob to node %v: out of capacity, to be healed", entry)
+				} else if !httpx.IsCausedByContextCanceled(err) {
 					log.Warnf("Put blob to node %v, error: %v", entry, err)
 				}
 			}
@@ -162,6 +263,8 @@ func (b *Broker) PutBlob(ctx context.Context, blob *blob.Blob) error {
 	if err := quorum.HandleWrite(ctx, qch, len(approvedEntries)); err != nil {
 		return err
 	}
+	// meta may be replaced
+	b.cache.Remove(key)
 
 	var okEntries []*spec.Entry
 	for i := 0; i < cap(ch); i++ {
@@ -191,8 +294,8 @@ func (b *Broker) PutBlob(ctx context.Context, blob *blob.Blob) error {
 						ch <- false
 					}
 				}()
-				rpc := b.nodeRPC.WithAddr(entry.Addr)
-				if err := rpc.PutBlob(blob); err != nil {
+				rpc := b.rpc(entry)
+				if err := rpc.PutBlob(blob, meta); err != nil {
 					ch <- false
 					if !httpx.IsCausedByContextCanceled(err) {
 						log.Warnf("Put blob to node %v: %v", entry, err)
@@ -217,3 +320,20 @@ func (b *Broker) PutBlob(ctx context.Context, blob *blob.Blob) error {
 	}
 	return nil
 }
+
+// existence vote of whether blob exists
+type existence struct {
+	exists bool
+	err    error
+}
+
+func (e *existence) Errored() bool {
+	return e.err != nil
+}
+
+func (e *existence) Data() interface{} {
+	if !e.exists {
+		return nil
+	}
+	return true
+}
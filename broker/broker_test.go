@@ -0,0 +1,101 @@
+package broker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/vechain/solidb/blob"
+	. "github.com/vechain/solidb/broker"
+	"github.com/vechain/solidb/crypto"
+	"github.com/vechain/solidb/kv"
+	"github.com/vechain/solidb/node"
+	"github.com/vechain/solidb/spec"
+	"github.com/vechain/solidb/specmgr"
+)
+
+// testCluster cluster of a single node, serving both node and broker routes
+type testCluster struct {
+	node   *node.Node
+	store  kv.Store
+	broker *Broker
+	server *httptest.Server
+}
+
+func newTestCluster(nodeOptions node.Options) *testCluster {
+	return newTestClusterWithBroker(nodeOptions, Options{})
+}
+
+func newTestClusterWithBroker(nodeOptions node.Options, brokerOptions Options) *testCluster {
+	store, err := kv.NewMemStore(kv.Options{})
+	if err != nil {
+		panic(err)
+	}
+	specMgr := specmgr.New(store)
+	n, err := node.New(store, specMgr, nodeOptions)
+	if err != nil {
+		panic(err)
+	}
+	b := New(store, specMgr, n, brokerOptions)
+	mux := http.NewServeMux()
+	mux.Handle(node.HTTPPathPrefix, node.NewHTTPHandler(n))
+	mux.Handle(HTTPPathPrefix, NewHTTPHandler(b))
+	server := httptest.NewServer(mux)
+
+	var slices []string
+	for i := 0; i < 16; i++ {
+		slices = append(slices, fmt.Sprintf("%x", i))
+	}
+	s := spec.Spec{SAT: spec.SAT{Entries: []spec.Entry{{
+		ID:     n.ID(),
+		Addr:   server.Listener.Addr().String(),
+		Slices: slices,
+	}}}}
+	master, err := crypto.GenerateIdentity()
+	if err != nil {
+		panic(err)
+	}
+	if err := n.Invite(master.ID(), &s, nil, nil); err != nil {
+		panic(err)
+	}
+	return &testCluster{n, store, b, server}
+}
+
+func (c *testCluster) Close() {
+	c.server.Close()
+	c.broker.Shutdown()
+	c.store.Close()
+}
+
+func (c *testCluster) do(method, path string, header http.Header, body []byte) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, c.server.URL+path, bytes.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+	return resp, data
+}
+
+// put put data through broker, and returns key
+func (c *testCluster) put(data []byte) blob.Key {
+	_, body := c.do(http.MethodPost, "/blobs", nil, data)
+	var resp node.PutBlobResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		panic(fmt.Sprintf("put: %s", body))
+	}
+	return resp.Key
+}
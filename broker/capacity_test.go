@@ -0,0 +1,53 @@
+package broker_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/node"
+)
+
+func TestCapacity(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir(os.TempDir(), "store")
+	defer os.RemoveAll(dir)
+	// usage at 96% of capacity, beyond the default high watermark
+	ioutil.WriteFile(filepath.Join(dir, "data"), make([]byte, 960), 0600)
+
+	c := newTestCluster(node.Options{StoreDir: dir, Capacity: 1000})
+	defer c.Close()
+	rpc := node.NewRPC().WithAddr(c.server.Listener.Addr().String()).WithSigner(c.node, c.node.ID())
+
+	status, err := rpc.GetStatus()
+	assert.Nil(err)
+	if assert.NotNil(status.Storage) {
+		assert.True(status.Storage.Full)
+		assert.Equal(int64(960), status.Storage.Used)
+	}
+
+	b := blob.New([]byte("data"))
+	err = rpc.PutBlob(b, nil)
+	assert.True(node.IsInsufficientStorage(err))
+	err = rpc.BatchPutBlobs([]*blob.Blob{b})
+	assert.True(node.IsInsufficientStorage(err))
+
+	resp, _ := c.do(http.MethodPost, "/blobs", nil, b.Data())
+	assert.NotEqual(http.StatusOK, resp.StatusCode, "no node admits the write")
+	has, err := rpc.HasBlob(b.Key())
+	assert.Nil(err)
+	assert.False(has)
+
+	// below high watermark
+	os.Remove(filepath.Join(dir, "data"))
+	c = newTestCluster(node.Options{StoreDir: dir, Capacity: 1000})
+	defer c.Close()
+	resp, _ = c.do(http.MethodPost, "/blobs", nil, b.Data())
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	status, _ = c.node.GetStatus()
+	assert.False(status.Storage.Full)
+}
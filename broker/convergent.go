@@ -0,0 +1,173 @@
+package broker
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/crypto"
+	"github.com/vechain/solidb/utils/httpx"
+)
+
+// DefaultMaxEncryptedObjectSize default max size of object uploaded with convergent encryption
+const DefaultMaxEncryptedObjectSize = 1 << 30
+
+// convergentTag separates convergent keys from other hashes of the same content
+var convergentTag = []byte("solidb convergent encryption v1")
+
+var (
+	errEncryptedObjectTooLarge = httpx.Error(errors.New("encrypted object too large"), http.StatusRequestEntityTooLarge)
+	errSecretMismatch          = httpx.Error(errors.New("content mismatches capability secret"), http.StatusBadRequest)
+)
+
+// Capability grants access to a convergently encrypted object.
+// Key addresses the ciphertext stored on nodes, and Secret decrypts it.
+type Capability struct {
+	Key    blob.Key
+	Secret crypto.Hash
+}
+
+// String encodes capability as 'storageKeyHex.secretHex'
+func (c *Capability) String() string {
+	return c.Key.ToHex() + "." + c.Secret.ToHex()
+}
+
+// ParseCapability parse capability from string encoded by Capability.String
+func ParseCapability(str string) (*Capability, error) {
+	parts := strings.Split(str, ".")
+	if len(parts) != 2 {
+		return nil, errors.New("parse capability: malformed")
+	}
+	key, err := blob.ParseHexKey(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "parse capability")
+	}
+	secret, err := crypto.HexToHash(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "parse capability")
+	}
+	return &Capability{*key, *secret}, nil
+}
+
+// newSecretHasher returns hasher of plaintext, whose sum is the secret
+func newSecretHasher() hash.Hash {
+	h := crypto.NewHasher()
+	h.Write(convergentTag)
+	return h
+}
+
+// checkSecret returns errSecretMismatch if sum of hasher mismatches secret
+func checkSecret(h hash.Hash, secret crypto.Hash) error {
+	if !bytes.Equal(h.Sum(nil), secret[:]) {
+		return errSecretMismatch
+	}
+	return nil
+}
+
+// newConvergentStream returns AES-CTR key stream started from offset of content.
+// IV is zero, which is safe since a secret never encrypts different content.
+func newConvergentStream(secret crypto.Hash, offset uint64) (cipher.Stream, error) {
+	block, err := aes.NewCipher(secret[:])
+	if err != nil {
+		return nil, err
+	}
+	var iv [aes.BlockSize]byte
+	binary.BigEndian.PutUint64(iv[8:], offset/aes.BlockSize)
+	stream := cipher.NewCTR(block, iv[:])
+	skip := make([]byte, offset%aes.BlockSize)
+	stream.XORKeyStream(skip, skip)
+	return stream, nil
+}
+
+// PutEncryptedObject encrypt data read from r before stored, so that nodes never see the plaintext.
+// The secret is derived from the content, so that the same content always results the same
+// ciphertext, and is still deduplicated.
+// Data is spooled to a temp file, since the secret is known only after all data read,
+// so it's limited by Options.MaxEncryptedObjectSize.
+func (b *Broker) PutEncryptedObject(ctx context.Context, r io.Reader) (*Capability, error) {
+	spool, err := ioutil.TempFile("", "solidb-spool")
+	if err != nil {
+		return nil, errors.Wrap(err, "put encrypted object")
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	hasher := newSecretHasher()
+	n, err := io.Copy(io.MultiWriter(spool, hasher), io.LimitReader(r, b.maxEncryptedObjectSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > b.maxEncryptedObjectSize {
+		return nil, errEncryptedObjectTooLarge
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrap(err, "put encrypted object")
+	}
+
+	var secret crypto.Hash
+	copy(secret[:], hasher.Sum(nil))
+	stream, err := newConvergentStream(secret, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "put encrypted object")
+	}
+	key, err := b.PutObject(ctx, &cipher.StreamReader{S: stream, R: spool}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Capability{*key, secret}, nil
+}
+
+// decryptWriter returns writer which decrypts content from offset, and writes plaintext to w.
+func decryptWriter(w io.Writer, secret crypto.Hash, offset uint64) (io.Writer, error) {
+	stream, err := newConvergentStream(secret, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &cipher.StreamWriter{S: stream, W: w}, nil
+}
+
+// decrypt the whole content, and verify it by secret
+func decrypt(secret crypto.Hash, ciphertext []byte) ([]byte, error) {
+	stream, err := newConvergentStream(secret, 0)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(ciphertext))
+	stream.XORKeyStream(plain, ciphertext)
+	h := newSecretHasher()
+	h.Write(plain)
+	if err := checkSecret(h, secret); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+// verifyEncryptedManifest decrypt the whole content of manifest, and verify it by secret
+func (b *Broker) verifyEncryptedManifest(ctx context.Context, m *blob.Manifest, secret crypto.Hash) error {
+	h := newSecretHasher()
+	w, err := decryptWriter(h, secret, 0)
+	if err != nil {
+		return err
+	}
+	if err := b.writeManifestRange(ctx, w, m, 0, m.Size); err != nil {
+		return err
+	}
+	return checkSecret(h, secret)
+}
+
+// isCapability returns whether str looks like a capability rather than a key
+func isCapability(str string) bool {
+	return strings.Contains(str, ".")
+}
@@ -0,0 +1,77 @@
+package broker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/blob"
+	. "github.com/vechain/solidb/broker"
+	"github.com/vechain/solidb/node"
+)
+
+func (c *testCluster) putEncrypted(data []byte) (int, *Capability) {
+	resp, body := c.do(http.MethodPost, "/blobs?encrypt=convergent", nil, data)
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	var putResp PutEncryptedResponse
+	if err := json.Unmarshal(body, &putResp); err != nil {
+		panic(err)
+	}
+	capability, err := ParseCapability(putResp.Capability)
+	if err != nil {
+		panic(err)
+	}
+	return resp.StatusCode, capability
+}
+
+func TestConvergent(t *testing.T) {
+	assert := assert.New(t)
+	c := newTestClusterWithBroker(node.Options{}, Options{MaxEncryptedObjectSize: 1 << 20})
+	defer c.Close()
+
+	small := []byte("hello world")
+	large := make([]byte, blob.DataLenHardLimit*2+100)
+	rand.Read(large)
+	for _, data := range [][]byte{small, large} {
+		_, capability := c.putEncrypted(data)
+		if !assert.NotNil(capability) {
+			continue
+		}
+		_, ciphertext := c.do(http.MethodGet, "/blobs/"+capability.Key.ToHex(), nil, nil)
+		assert.Equal(len(data), len(ciphertext))
+		assert.NotEqual(data, ciphertext)
+
+		resp, body := c.do(http.MethodGet, "/blobs/"+capability.String(), nil, nil)
+		assert.Equal(http.StatusOK, resp.StatusCode)
+		assert.Equal(data, body)
+		resp, _ = c.do(http.MethodHead, "/blobs/"+capability.String(), nil, nil)
+		assert.Equal(http.StatusOK, resp.StatusCode)
+		resp, body = c.do(http.MethodGet, "/blobs/"+capability.String(), http.Header{"Range": []string{"bytes=2-4"}}, nil)
+		assert.Equal(http.StatusPartialContent, resp.StatusCode)
+		assert.Equal(data[2:5], body)
+
+		// wrong secret
+		forged := *capability
+		forged.Secret[0] ^= 1
+		resp, _ = c.do(http.MethodGet, "/blobs/"+forged.String(), http.Header{"Range": []string{"bytes=2-4"}}, nil)
+		assert.Equal(http.StatusBadRequest, resp.StatusCode)
+		httpResp, err := http.Get(c.server.URL + "/blobs/" + forged.String())
+		if err == nil {
+			_, err = ioutil.ReadAll(httpResp.Body)
+			httpResp.Body.Close()
+			if err == nil {
+				assert.Equal(http.StatusBadRequest, httpResp.StatusCode)
+			}
+		}
+	}
+
+	status, capability := c.putEncrypted(bytes.Repeat([]byte{1}, 1<<20+1))
+	assert.Equal(http.StatusRequestEntityTooLarge, status)
+	assert.Nil(capability)
+}
@@ -1,12 +1,20 @@
 package broker
 
 import (
+	"bytes"
+	"encoding/json"
+	"hash"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/crypto"
 	"github.com/vechain/solidb/node"
 	"github.com/vechain/solidb/utils/httpx"
 )
@@ -18,48 +26,252 @@ func NewHTTPHandler(broker *Broker) http.Handler {
 	sub := router.PathPrefix(HTTPPathPrefix).Subrouter()
 
 	sub.Methods(http.MethodGet).Path("/blobs/{key}").HandlerFunc(httpx.WrapHandlerFunc(broker.handleGet))
+	sub.Methods(http.MethodHead).Path("/blobs/{key}").HandlerFunc(httpx.WrapHandlerFunc(broker.handleHas))
 	sub.Methods(http.MethodPost).Path("/blobs").HandlerFunc(httpx.WrapHandlerFunc(broker.handlePut))
+	sub.Methods(http.MethodPost).Path("/blobs:batchGet").HandlerFunc(httpx.WrapHandlerFunc(broker.handleBatchGet))
+	sub.Methods(http.MethodPost).Path("/blobs:batchPut").HandlerFunc(httpx.WrapHandlerFunc(broker.handleBatchPut))
+	sub.Methods(http.MethodPut).Path("/blobs/{key}/pins/{label}").HandlerFunc(httpx.WrapHandlerFunc(broker.handlePin))
+	sub.Methods(http.MethodDelete).Path("/blobs/{key}/pins/{label}").HandlerFunc(httpx.WrapHandlerFunc(broker.handleUnpin))
+	sub.Methods(http.MethodGet).Path("/stats").HandlerFunc(httpx.WrapHandlerFunc(broker.handleGetStats))
 	return router
 }
 
+// parseKeyOrCapability parse key, or a capability in place of key to get decrypted content
+func parseKeyOrCapability(str string) (*blob.Key, *crypto.Hash, error) {
+	if isCapability(str) {
+		c, err := ParseCapability(str)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &c.Key, &c.Secret, nil
+	}
+	key, err := blob.ParseHexKey(str)
+	return key, nil, err
+}
+
 func (b *Broker) handleGet(w http.ResponseWriter, req *http.Request) error {
-	vars := mux.Vars(req)
-	key, err := blob.ParseHexKey(vars["key"])
+	key, secret, err := parseKeyOrCapability(mux.Vars(req)["key"])
 	if err != nil {
 		return httpx.Error(err, http.StatusBadRequest)
 	}
 
-	blob, err := b.GetBlob(req.Context(), *key)
+	data, meta, err := b.GetBlobWithMeta(req.Context(), *key)
 	if err != nil {
 		return err
 	}
 
-	if blob.V == nil {
+	if data.V == nil {
 		return httpx.Error(nil, http.StatusNoContent)
 	}
 	w.Header().Set("Content-Type", httpx.OctetStreamContentType)
-	w.Write(blob.V.Data())
+	if meta != nil {
+		node.SetMetaHeader(w.Header(), meta)
+	}
+	if !blob.IsManifest(data.V.Data()) {
+		content := data.V.Data()
+		if secret != nil {
+			if content, err = decrypt(*secret, content); err != nil {
+				return err
+			}
+		}
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(content))
+		return nil
+	}
+
+	m, err := blob.ParseManifest(data.V.Data())
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	rng := &httpx.ByteRange{Start: 0, Length: m.Size}
+	status := http.StatusOK
+	// If-Range can never match, since no validator is provided
+	if req.Header.Get("If-Range") == "" {
+		r, err := httpx.ParseRange(req.Header.Get("Range"), m.Size)
+		if err != nil {
+			w.Header().Set("Content-Range", "bytes */"+strconv.FormatUint(m.Size, 10))
+			return httpx.Error(err, http.StatusRequestedRangeNotSatisfiable)
+		}
+		if r != nil {
+			rng = r
+			status = http.StatusPartialContent
+			w.Header().Set("Content-Range", rng.ContentRange(m.Size))
+		}
+	}
+	var (
+		out    io.Writer = w
+		hasher hash.Hash
+	)
+	if secret != nil {
+		if status == http.StatusPartialContent {
+			// a range can't be verified alone
+			if err := b.verifyEncryptedManifest(req.Context(), m, *secret); err != nil {
+				return err
+			}
+		} else {
+			// verified once all written, and the response is aborted on mismatch
+			hasher = newSecretHasher()
+			out = io.MultiWriter(w, hasher)
+		}
+		if out, err = decryptWriter(out, *secret, rng.Start); err != nil {
+			return err
+		}
+	}
+	w.Header().Set("Content-Length", strconv.FormatUint(rng.Length, 10))
+	w.WriteHeader(status)
+	if err := b.writeManifestRange(req.Context(), out, m, rng.Start, rng.Length); err != nil {
+		log.Warnf("write manifest %s: %v", key.ToHex(), err)
+		// headers already sent, abort the response
+		panic(http.ErrAbortHandler)
+	}
+	if hasher != nil {
+		if err := checkSecret(hasher, *secret); err != nil {
+			log.Warnf("write manifest %s: %v", key.ToHex(), err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+	return nil
+}
+
+func (b *Broker) handleHas(w http.ResponseWriter, req *http.Request) error {
+	key, _, err := parseKeyOrCapability(mux.Vars(req)["key"])
+	if err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+
+	has, err := b.HasBlob(req.Context(), *key)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return httpx.Error(nil, http.StatusNoContent)
+	}
 	return nil
 }
 
 func (b *Broker) handlePut(w http.ResponseWriter, req *http.Request) error {
-	if req.ContentLength > blob.DataLenHardLimit {
-		return httpx.Error(errors.New("content length exceeds limit"), http.StatusNotAcceptable)
+	switch mode := req.URL.Query().Get("encrypt"); mode {
+	case "":
+	case "convergent":
+		return b.handlePutEncrypted(w, req)
+	default:
+		return httpx.Error(errors.Errorf("unsupported encryption %s", mode), http.StatusBadRequest)
+	}
+	// body is read progressively, so content length can be unknown
+	meta := node.MetaFromHeader(req.Header)
+	if meta != nil {
+		// reject oversized meta before consuming the body
+		if _, err := meta.Encode(); err != nil {
+			return httpx.Error(err, http.StatusBadRequest)
+		}
+	}
+	key, err := b.PutObject(req.Context(), req.Body, meta)
+	if err != nil {
+		return err
+	}
+
+	return httpx.ResponseJSON(w, node.PutBlobResponse{
+		Key: *key,
+	})
+}
+
+func (b *Broker) handlePutEncrypted(w http.ResponseWriter, req *http.Request) error {
+	// meta is stored in plaintext on nodes
+	if node.MetaFromHeader(req.Header) != nil {
+		return httpx.Error(errors.New("meta not supported for encrypted blob"), http.StatusBadRequest)
 	}
-	if req.ContentLength < 0 {
-		return httpx.Error(errors.New("content length unknown"), http.StatusNotAcceptable)
+	if req.ContentLength > b.maxEncryptedObjectSize {
+		return errEncryptedObjectTooLarge
 	}
-	data := make([]byte, req.ContentLength)
-	if _, err := io.ReadFull(req.Body, data); err != nil {
+	c, err := b.PutEncryptedObject(req.Context(), req.Body)
+	if err != nil {
 		return err
 	}
+	return httpx.ResponseJSON(w, &PutEncryptedResponse{
+		Key:        c.Key,
+		Capability: c.String(),
+	})
+}
+
+func (b *Broker) handleBatchGet(w http.ResponseWriter, req *http.Request) error {
+	var reqBody node.BatchGetRequest
+	if err := json.NewDecoder(req.Body).Decode(&reqBody); err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+	if len(reqBody.Keys) > node.MaxBatchKeys {
+		return httpx.Error(errors.New("too many keys"), http.StatusBadRequest)
+	}
 
-	blob := blob.New(data)
-	if err := b.PutBlob(req.Context(), blob); err != nil {
+	w.Header().Set("Content-Type", httpx.OctetStreamContentType)
+	written := false
+	err := b.BatchGetBlobs(req.Context(), reqBody.Keys, func(item *blobio.StreamItem) error {
+		written = true
+		return blobio.WriteStreamItem(w, item)
+	})
+	if err != nil {
+		if !written {
+			return err
+		}
+		log.Warnf("batch get: %v", err)
+		// headers already sent, abort the response
+		panic(http.ErrAbortHandler)
+	}
+	if err := blobio.EndWriteBlob(w); err != nil {
+		log.Warnf("batch get: %v", err)
+	}
+	return nil
+}
+
+func (b *Broker) handleBatchPut(w http.ResponseWriter, req *http.Request) error {
+	var blobs []*blob.Blob
+	for {
+		item, err := blobio.ReadStreamItem(req.Body)
+		if err != nil {
+			return httpx.Error(err, http.StatusBadRequest)
+		}
+		if item == nil {
+			break
+		}
+		if item.Blob == nil {
+			return httpx.Error(errors.New("absent blob"), http.StatusBadRequest)
+		}
+		if len(blobs) >= node.MaxBatchKeys {
+			return httpx.Error(errors.New("too many blobs"), http.StatusBadRequest)
+		}
+		blobs = append(blobs, item.Blob)
+	}
+
+	results, err := b.BatchPutBlobs(req.Context(), blobs)
+	if err != nil {
 		return err
 	}
+	return httpx.ResponseJSON(w, node.BatchPutResponse{
+		Results: results,
+	})
+}
 
-	return httpx.ResponseJSON(w, node.PutBlobResponse{
-		Key: blob.Key(),
+func (b *Broker) handlePinAction(w http.ResponseWriter, req *http.Request, pin bool) error {
+	vars := mux.Vars(req)
+	key, err := blob.ParseHexKey(vars["key"])
+	if err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+	if pin {
+		return b.PinBlob(req.Context(), *key, vars["label"])
+	}
+	return b.UnpinBlob(req.Context(), *key, vars["label"])
+}
+
+func (b *Broker) handlePin(w http.ResponseWriter, req *http.Request) error {
+	return b.handlePinAction(w, req, true)
+}
+
+func (b *Broker) handleUnpin(w http.ResponseWriter, req *http.Request) error {
+	return b.handlePinAction(w, req, false)
+}
+
+func (b *Broker) handleGetStats(w http.ResponseWriter, req *http.Request) error {
+	return httpx.ResponseJSON(w, &StatsResponse{
+		Cache: b.CacheStats(),
 	})
 }
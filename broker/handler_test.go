@@ -0,0 +1,83 @@
+package broker_test
+
+import (
+	"math/rand"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/node"
+)
+
+func TestHead(t *testing.T) {
+	assert := assert.New(t)
+	c := newTestCluster(node.Options{})
+	defer c.Close()
+
+	key := c.put([]byte("hello"))
+	missing := blob.KeyOfData([]byte("missing"))
+
+	resp, body := c.do(http.MethodHead, "/blobs/"+key.ToHex(), nil, nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Empty(body)
+	resp, _ = c.do(http.MethodHead, "/blobs/"+missing.ToHex(), nil, nil)
+	assert.Equal(http.StatusNoContent, resp.StatusCode)
+	resp, _ = c.do(http.MethodHead, "/blobs/xyz", nil, nil)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	rpc := node.NewRPC().WithAddr(c.server.Listener.Addr().String()).WithSigner(c.node, c.node.ID())
+	has, err := rpc.HasBlob(key)
+	assert.Nil(err)
+	assert.True(has)
+	has, err = rpc.HasBlob(missing)
+	assert.Nil(err)
+	assert.False(has)
+}
+
+func TestRange(t *testing.T) {
+	assert := assert.New(t)
+	c := newTestCluster(node.Options{})
+	defer c.Close()
+
+	small := []byte("0123456789")
+	smallKey := c.put(small)
+	large := make([]byte, blob.DataLenHardLimit*2+100)
+	rand.Read(large)
+	largeKey := c.put(large)
+
+	rangeHeader := func(v string) http.Header {
+		return http.Header{"Range": []string{v}}
+	}
+
+	resp, body := c.do(http.MethodGet, "/blobs/"+smallKey.ToHex(), rangeHeader("bytes=2-4"), nil)
+	assert.Equal(http.StatusPartialContent, resp.StatusCode)
+	assert.Equal("234", string(body))
+
+	resp, body = c.do(http.MethodGet, "/blobs/"+largeKey.ToHex(), nil, nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("bytes", resp.Header.Get("Accept-Ranges"))
+	assert.Equal(large, body)
+
+	// across chunk boundary
+	start := blob.DataLenHardLimit - 10
+	resp, body = c.do(http.MethodGet, "/blobs/"+largeKey.ToHex(), rangeHeader("bytes=65526-65545"), nil)
+	assert.Equal(http.StatusPartialContent, resp.StatusCode)
+	assert.Equal("bytes 65526-65545/131172", resp.Header.Get("Content-Range"))
+	assert.Equal(large[start:start+20], body)
+
+	resp, body = c.do(http.MethodGet, "/blobs/"+largeKey.ToHex(), rangeHeader("bytes=-50"), nil)
+	assert.Equal(http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(large[len(large)-50:], body)
+
+	resp, _ = c.do(http.MethodGet, "/blobs/"+largeKey.ToHex(), rangeHeader("bytes=200000-"), nil)
+	assert.Equal(http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	assert.Equal("bytes */131172", resp.Header.Get("Content-Range"))
+
+	// If-Range never matches, so the whole content is returned
+	h := rangeHeader("bytes=0-9")
+	h.Set("If-Range", `"etag"`)
+	resp, body = c.do(http.MethodGet, "/blobs/"+largeKey.ToHex(), h, nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(len(large), len(body))
+}
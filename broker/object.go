@@ -0,0 +1,164 @@
+package broker
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobio"
+)
+
+// chunkSize size of chunks which large object split into
+const chunkSize = blob.DataLenHardLimit
+
+// readChunk read at most chunkSize bytes from r.
+// io.EOF returned if no more data.
+func readChunk(r io.Reader) ([]byte, error) {
+	buf := make([]byte, chunkSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// PutObject store data read from r.
+// Data larger than blob.DataLenHardLimit is split into chunks, and a manifest blob
+// listing chunk keys is stored. The key of data blob or manifest blob is returned.
+// Meta is optional, and stored along with the returned key.
+func (b *Broker) PutObject(ctx context.Context, r io.Reader, meta *blobio.Meta) (*blob.Key, error) {
+	first, err := readChunk(r)
+	if err == io.EOF {
+		first = []byte{}
+	} else if err != nil {
+		return nil, err
+	}
+	next, err := readChunk(r)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	// data which looks like a manifest is always wrapped, to avoid ambiguity
+	if next == nil && !blob.IsManifest(first) {
+		data := blob.New(first)
+		if err := b.PutBlob(ctx, data, meta); err != nil {
+			return nil, err
+		}
+		key := data.Key()
+		return &key, nil
+	}
+
+	var (
+		chunkKeys []blob.Key
+		size      uint64
+		chunk     = first
+	)
+	for chunk != nil {
+		data := blob.New(chunk)
+		if err := b.PutBlob(ctx, data, nil); err != nil {
+			return nil, errors.Wrap(err, "put chunk")
+		}
+		chunkKeys = append(chunkKeys, data.Key())
+		size += uint64(len(chunk))
+
+		chunk = next
+		if next != nil {
+			if next, err = readChunk(r); err != nil && err != io.EOF {
+				return nil, err
+			}
+		}
+	}
+
+	manifests := blob.BuildManifests(chunkKeys, chunkSize, size)
+	for i, m := range manifests {
+		// meta is attached to the root manifest only
+		var mm *blobio.Meta
+		if i == len(manifests)-1 {
+			mm = meta
+		}
+		if err := b.PutBlob(ctx, m, mm); err != nil {
+			return nil, errors.Wrap(err, "put manifest")
+		}
+	}
+	key := manifests[len(manifests)-1].Key()
+	return &key, nil
+}
+
+// getManifest get and parse the manifest blob by key.
+func (b *Broker) getManifest(ctx context.Context, key blob.Key) (*blob.Manifest, error) {
+	data, err := b.GetBlob(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if data.V == nil {
+		return nil, errors.Errorf("manifest %s not found", key.ToHex())
+	}
+	return blob.ParseManifest(data.V.Data())
+}
+
+// writeManifest stream data covered by manifest to w.
+// Every chunk is checked by key and size.
+func (b *Broker) writeManifest(ctx context.Context, w io.Writer, m *blob.Manifest) error {
+	return b.writeManifestRange(ctx, w, m, 0, m.Size)
+}
+
+// writeManifestRange write a range of content referred by manifest.
+// Only chunks overlapped with the range are fetched.
+func (b *Broker) writeManifestRange(ctx context.Context, w io.Writer, m *blob.Manifest, offset, length uint64) error {
+	span := m.ChildSpan()
+	end := offset + length
+	for i, key := range m.Keys {
+		childStart := uint64(i) * span
+		expected := span
+		if i == len(m.Keys)-1 {
+			expected = m.Size - childStart
+		}
+		childEnd := childStart + expected
+		if childEnd <= offset {
+			continue
+		}
+		if childStart >= end {
+			break
+		}
+		// range relative to the child
+		lo, hi := uint64(0), expected
+		if offset > childStart {
+			lo = offset - childStart
+		}
+		if end < childEnd {
+			hi = end - childStart
+		}
+
+		if m.Level > 0 {
+			child, err := b.getManifest(ctx, key)
+			if err != nil {
+				return err
+			}
+			if child.Size != expected {
+				return errors.New("write manifest: sub-manifest size mismatch")
+			}
+			if err := b.writeManifestRange(ctx, w, child, lo, hi-lo); err != nil {
+				return err
+			}
+			continue
+		}
+
+		chunk, err := b.GetBlob(ctx, key)
+		if err != nil {
+			return err
+		}
+		if chunk.V == nil {
+			return errors.Errorf("write manifest: chunk %s not found", key.ToHex())
+		}
+		if uint64(len(chunk.V.Data())) != expected {
+			return errors.New("write manifest: chunk size mismatch")
+		}
+		if _, err := w.Write(chunk.V.Data()[lo:hi]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package broker
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/node"
+	"github.com/vechain/solidb/quorum"
+	"github.com/vechain/solidb/specmgr"
+	"github.com/vechain/solidb/utils/httpx"
+)
+
+// PinBlob pin a blob with label, on nodes the blob belongs to.
+// Pinned blobs and blobs reachable from them survive GC.
+func (b *Broker) PinBlob(ctx context.Context, key blob.Key, label string) error {
+	return b.writeAll(ctx, key, func(rpc *node.RPC) error {
+		return rpc.PinBlob(key, label)
+	})
+}
+
+// UnpinBlob remove pin with label.
+func (b *Broker) UnpinBlob(ctx context.Context, key blob.Key, label string) error {
+	return b.writeAll(ctx, key, func(rpc *node.RPC) error {
+		return rpc.UnpinBlob(key, label)
+	})
+}
+
+// writeAll perform write operation to nodes the key belongs to, according to approved spec.
+func (b *Broker) writeAll(ctx context.Context, key blob.Key, op func(rpc *node.RPC) error) error {
+	approved, err := b.specMgr.GetByTag(specmgr.TagApproved)
+	if err != nil {
+		return err
+	}
+	if approved.V == nil {
+		return errors.New("no approved spec")
+	}
+
+	entries := approved.V.SAT.Locate(key.ToHex())
+	ch := make(chan quorum.Vote, len(entries))
+	for _, entry := range entries {
+		entry := entry
+		b.run(func() {
+			defer func() {
+				if err := recover(); err != nil {
+					e := errors.Errorf("write: goroutine recovered %v", err)
+					log.Warnln(e)
+					ch <- &result{err: e}
+				}
+			}()
+			r := result{entry: &entry}
+			if err := op(b.rpc(entry)); err != nil {
+				r.err = err
+				if !httpx.IsCausedByContextCanceled(err) {
+					log.Warnf("Write to node %v: %v", entry, err)
+				}
+			}
+			ch <- &r
+		})
+	}
+	return quorum.HandleWrite(ctx, ch, len(entries))
+}
@@ -0,0 +1,19 @@
+package broker
+
+import (
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobcache"
+)
+
+// StatsResponse runtime statistics of broker
+type StatsResponse struct {
+	Cache *blobcache.Stats `json:"cache,omitempty"`
+}
+
+// PutEncryptedResponse response body of put with convergent encryption
+type PutEncryptedResponse struct {
+	// Key key of stored ciphertext
+	Key blob.Key `json:"key"`
+	// Capability to get decrypted content, as 'key.secret'
+	Capability string `json:"capability"`
+}
@@ -0,0 +1,286 @@
+package master
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/cmd/master/mod"
+	"github.com/vechain/solidb/crypto"
+	"github.com/vechain/solidb/node"
+	"github.com/vechain/solidb/spec"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// signingRequest approvals to be collected from admins, exported as a file.
+// Content to be approved is included, for admins to review.
+type signingRequest struct {
+	Approvals node.Approvals       `json:"approvals"`
+	Spec      *spec.Spec           `json:"spec,omitempty"`
+	Admins    *node.AdminSet       `json:"admins,omitempty"`
+	GC        *node.GCSweepRequest `json:"gc,omitempty"`
+}
+
+// check checks that subject matches the content
+func (r *signingRequest) check() error {
+	subject := r.Approvals.Subject
+	var hash crypto.Hash
+	switch subject.Action {
+	case node.ActionProposeSpec, node.ActionApproveSpec:
+		if r.Spec == nil || r.Spec.Revision != subject.Revision {
+			return errors.New("spec mismatch")
+		}
+		hash = r.Spec.Hash()
+	case node.ActionSetAdmins:
+		if r.Admins == nil {
+			return errors.New("admins absent")
+		}
+		if err := r.Admins.Validate(); err != nil {
+			return err
+		}
+		hash = r.Admins.Hash()
+	case node.ActionGC:
+		if r.GC == nil {
+			return errors.New("gc absent")
+		}
+		if r.GC.Grace < node.MinGCGrace {
+			return errors.Errorf("grace should be at least %v", node.MinGCGrace)
+		}
+		if r.GC.Nonce == "" || subject.Expiry == 0 {
+			return errors.New("gc approvals should be one-time and expire")
+		}
+		hash = r.GC.Hash()
+	default:
+		return errors.Errorf("unknown action %s", subject.Action)
+	}
+	if subject.ContentHash != hash.ToHex() {
+		return errors.New("content hash mismatch")
+	}
+	return nil
+}
+
+func (r *signingRequest) summary() string {
+	subject := r.Approvals.Subject
+	var s string
+	switch subject.Action {
+	case node.ActionProposeSpec, node.ActionApproveSpec:
+		s = fmt.Sprintf("%s spec rev%d of %d nodes", subject.Action, subject.Revision, len(r.Spec.SAT.Entries))
+	case node.ActionSetAdmins:
+		s = fmt.Sprintf("set %d of %d admins", r.Admins.Threshold, len(r.Admins.Admins))
+	case node.ActionGC:
+		s = fmt.Sprintf("gc with grace %v on spec rev%d", r.GC.Grace, subject.Revision)
+	}
+	s += ", cluster " + subject.ClusterID
+	if subject.Expiry != 0 {
+		s += ", expires at " + time.Unix(subject.Expiry, 0).String()
+	}
+	return s
+}
+
+func loadSigningRequest(path string) (*signingRequest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r signingRequest
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, errors.Wrap(err, "load signing request")
+	}
+	if err := r.check(); err != nil {
+		return nil, errors.Wrap(err, "load signing request")
+	}
+	return &r, nil
+}
+
+func (r *signingRequest) save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// newSigningRequest create signing request file in current dir, to be signed by admins
+func newSigningRequest(ctx *cli.Context, m *mod.Model, r *signingRequest) error {
+	r.Approvals.Subject.ClusterID = m.ClusterID()
+	if d := ctx.Duration(expireInFlag.Name); d > 0 {
+		r.Approvals.Subject.Expiry = time.Now().Add(d).Unix()
+	}
+	if err := r.check(); err != nil {
+		return err
+	}
+	path := r.Approvals.Subject.Action + ".req"
+	if r.Spec != nil {
+		path = fmt.Sprintf("%s-%d.req", r.Approvals.Subject.Action, r.Spec.Revision)
+	}
+	if err := r.save(path); err != nil {
+		return err
+	}
+	fmt.Printf("approvals of %d admins required, signing request written to %s\n", m.Admins().Threshold, path)
+	fmt.Printf("sign it by 'solidb sign --key admin-key-file %s', then 'solidb submit %s'\n", path, path)
+	return nil
+}
+
+// newAdminKey generate key of admin
+func newAdminKey(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		cli.ShowSubcommandHelp(ctx)
+		return errArgNum
+	}
+	keyType, err := crypto.ParseKeyType(ctx.String(keyTypeFlag.Name))
+	if err != nil {
+		return err
+	}
+	identity, err := crypto.GenerateIdentityOfType(keyType)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(ctx.Args().First(), []byte(hex.EncodeToString(identity.PrivateKey())), 0600); err != nil {
+		return err
+	}
+	fmt.Println("admin ID:", identity.ID())
+	return nil
+}
+
+// setAdmins replace admins of cluster
+func setAdmins(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		cli.ShowSubcommandHelp(ctx)
+		return errArgNum
+	}
+	m, err := mod.Current()
+	if err != nil {
+		return err
+	}
+	admins := &node.AdminSet{
+		Admins:    ctx.Args(),
+		Threshold: ctx.Int(thresholdFlag.Name),
+	}
+	if err := admins.Validate(); err != nil {
+		return err
+	}
+	r := &signingRequest{
+		Approvals: node.Approvals{Subject: node.ApprovalSubject{
+			ClusterID:   m.ClusterID(),
+			Action:      node.ActionSetAdmins,
+			ContentHash: admins.Hash().ToHex(),
+		}},
+		Admins: admins,
+	}
+	if current := m.Admins(); current != nil {
+		r.Approvals.Subject.BaseHash = current.Hash().ToHex()
+		return newSigningRequest(ctx, m, r)
+	}
+	// set by master alone for the first time
+	return submitRequest(m, r)
+}
+
+// sign sign a signing request by admin key
+func sign(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		cli.ShowSubcommandHelp(ctx)
+		return errArgNum
+	}
+	path := ctx.Args().First()
+	r, err := loadSigningRequest(path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(ctx.String(keyFlag.Name))
+	if err != nil {
+		return err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return err
+	}
+	identity, err := crypto.NewIdentity(key)
+	if err != nil {
+		return err
+	}
+	fmt.Println(r.summary())
+	if err := r.Approvals.Sign(identity); err != nil {
+		return err
+	}
+	if err := r.save(path); err != nil {
+		return err
+	}
+	fmt.Printf("signed by %s, %d signatures\n", identity.ID(), len(r.Approvals.Signatures))
+	return nil
+}
+
+// submit submit a signing request signed by enough admins
+func submit(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		cli.ShowSubcommandHelp(ctx)
+		return errArgNum
+	}
+	m, err := mod.Current()
+	if err != nil {
+		return err
+	}
+	r, err := loadSigningRequest(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	return submitRequest(m, r)
+}
+
+func submitRequest(m *mod.Model, r *signingRequest) error {
+	subject := r.Approvals.Subject
+	if subject.ClusterID != m.ClusterID() {
+		return errors.New("not of the cluster")
+	}
+	var approvals *node.Approvals
+	if admins := m.Admins(); admins != nil {
+		count, err := r.Approvals.Count(admins)
+		if err != nil {
+			return err
+		}
+		if count < admins.Threshold {
+			return errors.Errorf("approved by %d admins, %d required", count, admins.Threshold)
+		}
+		approvals = &r.Approvals
+	}
+
+	switch subject.Action {
+	case node.ActionProposeSpec:
+		return doPropose(m, r.Spec, approvals)
+	case node.ActionApproveSpec:
+		proposed, err := m.LoadSpec(mod.StageProposed)
+		if err != nil {
+			return err
+		}
+		if proposed.V == nil || proposed.V.Hash() != r.Spec.Hash() {
+			return errors.New("not the proposed spec")
+		}
+		return doApprove(m, proposed.V, approvals)
+	case node.ActionSetAdmins:
+		nodeLocs, err := allNodeLocs(m)
+		if err != nil {
+			return err
+		}
+		rpc := node.NewRPC().WithApprovals(approvals)
+		for _, loc := range nodeLocs {
+			if err := rpc.WithAddr(loc.addr).WithIdentity(m.Identity(), loc.id).SetAdmins(r.Admins); err != nil {
+				return errors.Wrapf(err, "node %s", loc.addr)
+			}
+		}
+		m.SetAdmins(r.Admins)
+		return m.Save()
+	case node.ActionGC:
+		approved, err := gcSpec(m)
+		if err != nil {
+			return err
+		}
+		if approved.Revision != subject.Revision {
+			return errors.New("spec changed since requested")
+		}
+		return doGC(m, r.GC, approvals)
+	}
+	return errors.Errorf("unknown action %s", subject.Action)
+}
@@ -0,0 +1,115 @@
+package master
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/cmd/master/mod"
+	"github.com/vechain/solidb/crypto"
+	"github.com/vechain/solidb/node"
+	"github.com/vechain/solidb/spec"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// gc collects blobs not reachable from pins.
+// If admins are configured, a signing request is created instead, and GC runs once it's submitted.
+func gc(ctx *cli.Context) error {
+	m, err := mod.Current()
+	if err != nil {
+		return err
+	}
+	approved, err := gcSpec(m)
+	if err != nil {
+		return err
+	}
+	sweepReq := &node.GCSweepRequest{Grace: ctx.Duration(graceFlag.Name)}
+	if m.Admins() != nil {
+		if ctx.Duration(expireInFlag.Name) <= 0 {
+			return errors.New("approvals of gc should expire, specify --" + expireInFlag.Name)
+		}
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		sweepReq.Nonce = hex.EncodeToString(nonce)
+		return newSigningRequest(ctx, m, &signingRequest{
+			Approvals: node.Approvals{Subject: node.ApprovalSubject{
+				Action:      node.ActionGC,
+				Revision:    approved.Revision,
+				ContentHash: sweepReq.Hash().ToHex(),
+			}},
+			GC: sweepReq,
+		})
+	}
+	return doGC(m, sweepReq, nil)
+}
+
+// gcSpec returns the approved spec, if no spec transition in flight
+func gcSpec(m *mod.Model) (*spec.Spec, error) {
+	approved, err := m.LoadSpec(mod.StageApproved)
+	if err != nil {
+		return nil, err
+	}
+	proposed, err := m.LoadSpec(mod.StageProposed)
+	if err != nil {
+		return nil, err
+	}
+	if approved.V == nil || proposed.V == nil {
+		return nil, errors.New("no approved spec")
+	}
+	if approved.V.Revision != proposed.V.Revision {
+		return nil, errors.New("spec transition in flight")
+	}
+	return approved.V, nil
+}
+
+// doGC runs GC mark and sweep on all nodes, with sweeps approved by admins if configured
+func doGC(m *mod.Model, sweepReq *node.GCSweepRequest, approvals *node.Approvals) error {
+	approved, err := gcSpec(m)
+	if err != nil {
+		return err
+	}
+	if sweepReq.Grace < node.MinGCGrace {
+		return errors.Errorf("grace should be at least %v", node.MinGCGrace)
+	}
+
+	var nodeLocs []nodeLoc
+	for _, entry := range approved.SAT.Entries {
+		nodeLocs = append(nodeLocs, nodeLoc{
+			id:   entry.ID,
+			addr: entry.Addr,
+		})
+	}
+	for status := range queryNodeStatus(m, nodeLocs) {
+		if status.err != nil {
+			return errors.Wrap(status.err, "query status")
+		}
+		r := status.status.SpecRevisions
+		if r.Newest != approved.Revision || r.Approved != approved.Revision {
+			return errors.New("spec transition in flight")
+		}
+	}
+
+	epoch := time.Now().UnixNano()
+	rpc := node.NewRPC()
+	for _, loc := range nodeLocs {
+		resp, err := rpc.WithAddr(loc.addr).WithIdentity(m.Identity(), loc.id).MarkGC(epoch)
+		if err != nil {
+			return errors.Wrap(err, "mark, nothing deleted")
+		}
+		fmt.Printf("%s\t%s\tmarked\t%d pins, %d reached\n", crypto.AbbrevID(loc.id), loc.addr, resp.PinCount, resp.ReachedCount)
+	}
+
+	rpc = rpc.WithApprovals(approvals)
+	for _, loc := range nodeLocs {
+		resp, err := rpc.WithAddr(loc.addr).WithIdentity(m.Identity(), loc.id).SweepGC(epoch, sweepReq)
+		if err != nil {
+			return errors.Wrap(err, "sweep")
+		}
+		fmt.Printf("%s\t%s\tswept\t%d blobs, %d candidates, %d deleted\n", crypto.AbbrevID(loc.id), loc.addr, resp.BlobCount, resp.CandidateCount, resp.DeletedCount)
+	}
+	return nil
+}
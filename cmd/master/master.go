@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/vechain/solidb/cmd/master/draft"
@@ -13,6 +14,7 @@ import (
 	ncmd "github.com/vechain/solidb/cmd/node"
 	"github.com/vechain/solidb/crypto"
 	"github.com/vechain/solidb/node"
+	"github.com/vechain/solidb/spec"
 	"github.com/vechain/solidb/utils/fpath"
 	cli "gopkg.in/urfave/cli.v1"
 )
@@ -27,6 +29,7 @@ var (
 			Usage:     "create a new cluster",
 			Flags: []cli.Flag{
 				replicasFlag,
+				keyTypeFlag,
 			},
 		},
 		{
@@ -70,6 +73,9 @@ var (
 			Action: propose,
 			Name:   "propose",
 			Usage:  "dispatch spec to nodes",
+			Flags: []cli.Flag{
+				expireInFlag,
+			},
 		},
 		{
 			Action: sync,
@@ -80,6 +86,60 @@ var (
 			Action: approve,
 			Name:   "approve",
 			Usage:  "notify nodes that the spec has been approved",
+			Flags: []cli.Flag{
+				expireInFlag,
+			},
+		},
+		{
+			Action: gc,
+			Name:   "gc",
+			Usage:  "collect blobs not reachable from pins",
+			Flags: []cli.Flag{
+				graceFlag,
+				expireInFlag,
+			},
+		},
+		{
+			Action:    newAdminKey,
+			Name:      "new-admin-key",
+			ArgsUsage: "path",
+			Usage:     "generate key of admin into file",
+			Flags: []cli.Flag{
+				keyTypeFlag,
+			},
+		},
+		{
+			Action:    setAdmins,
+			Name:      "admins",
+			ArgsUsage: "admin-id...",
+			Usage:     "set admins, threshold of whom should approve spec changes and GC",
+			Flags: []cli.Flag{
+				thresholdFlag,
+				expireInFlag,
+			},
+		},
+		{
+			Action:    sign,
+			Name:      "sign",
+			ArgsUsage: "request-file",
+			Usage:     "sign a signing request by admin key",
+			Flags: []cli.Flag{
+				keyFlag,
+			},
+		},
+		{
+			Action:    submit,
+			Name:      "submit",
+			ArgsUsage: "request-file",
+			Usage:     "submit a signing request approved by enough admins",
+		},
+		{
+			Action: rotateKey,
+			Name:   "rotate-key",
+			Usage:  "replace master key on all nodes",
+			Flags: []cli.Flag{
+				keyTypeFlag,
+			},
 		},
 	}
 
@@ -88,6 +148,11 @@ var (
 		Usage: "Replicas of solidb",
 		Value: 2,
 	}
+	keyTypeFlag = cli.StringFlag{
+		Name:  "key-type",
+		Usage: "Type of master key (p256|ed25519)",
+		Value: string(crypto.KeyTypeP256),
+	}
 	weightFlag = cli.UintFlag{
 		Name:  "weight",
 		Usage: "Weight of node",
@@ -97,6 +162,24 @@ var (
 		Name:  "addr",
 		Usage: "Address of node",
 	}
+	thresholdFlag = cli.IntFlag{
+		Name:  "threshold",
+		Usage: "Count of admins required to approve",
+		Value: 1,
+	}
+	expireInFlag = cli.DurationFlag{
+		Name:  "expire-in",
+		Usage: "Period approvals of admins stay valid, 0 for never",
+	}
+	keyFlag = cli.StringFlag{
+		Name:  "key",
+		Usage: "Path of admin key file",
+	}
+	graceFlag = cli.DurationFlag{
+		Name:  "grace",
+		Usage: "Period an unreachable blob survives before deleted",
+		Value: time.Hour * 24 * 7,
+	}
 )
 
 var errArgNum = errors.New("incorrect num of args")
@@ -131,8 +214,12 @@ func _new(ctx *cli.Context) error {
 		return errors.New("db exists")
 	}
 
+	keyType, err := crypto.ParseKeyType(ctx.String(keyTypeFlag.Name))
+	if err != nil {
+		return err
+	}
 	replicas := ctx.Int(replicasFlag.Name)
-	m, err := mod.New(dir, replicas)
+	m, err := mod.New(dir, replicas, keyType)
 	if err != nil {
 		return err
 	}
@@ -153,7 +240,10 @@ func list(ctx *cli.Context) error {
 		return err
 	}
 
-	fmt.Println("cluster ID:", m.Identity().ID())
+	fmt.Println("cluster ID:", m.ClusterID())
+	if m.Identity().ID() != m.ClusterID() {
+		fmt.Println("master ID:", m.Identity().ID())
+	}
 	draft := m.Draft()
 	fmt.Println("replicas:", draft.Replicas)
 
@@ -185,8 +275,8 @@ func status(ctx *cli.Context) error {
 		})
 	}
 
-	statusChan := queryNodeStatus(nodeLocs)
-	syncStatusChan := queryNodeSyncStatus(nodeLocs, proposed.V.Revision)
+	statusChan := queryNodeStatus(m, nodeLocs)
+	syncStatusChan := queryNodeSyncStatus(m, nodeLocs, proposed.V.Revision)
 
 	i := 0
 	for status := range statusChan {
@@ -220,7 +310,7 @@ func add(ctx *cli.Context) error {
 	}
 
 	rpc := node.NewRPC().WithAddr(addr).WithIdentity(m.Identity(), "")
-	nodeID, err := rpc.Invite(approved.V)
+	nodeID, err := rpc.Invite(approved.V, m.Rotations(), m.Admins())
 	if err != nil {
 		return err
 	}
@@ -303,7 +393,22 @@ func propose(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	rpc := node.NewRPC()
+	if m.Admins() != nil {
+		return newSigningRequest(ctx, m, &signingRequest{
+			Approvals: node.Approvals{Subject: node.ApprovalSubject{
+				Action:      node.ActionProposeSpec,
+				Revision:    s.Revision,
+				ContentHash: s.Hash().ToHex(),
+			}},
+			Spec: s,
+		})
+	}
+	return doPropose(m, s, nil)
+}
+
+// doPropose send spec to nodes, along with approvals of admins if configured
+func doPropose(m *mod.Model, s *spec.Spec, approvals *node.Approvals) error {
+	rpc := node.NewRPC().WithApprovals(approvals)
 	for _, e := range s.SAT.Entries {
 		rpc := rpc.WithAddr(e.Addr).WithIdentity(m.Identity(), e.ID)
 		if err := rpc.ProposeSpec(*s); err != nil {
@@ -363,7 +468,7 @@ func approve(ctx *cli.Context) error {
 		})
 	}
 
-	statusChan := queryNodeStatus(nodeLocs)
+	statusChan := queryNodeStatus(m, nodeLocs)
 	for status := range statusChan {
 		if status.err != nil {
 			return errors.Wrap(err, "query status")
@@ -373,12 +478,27 @@ func approve(ctx *cli.Context) error {
 		}
 	}
 
-	rpc := node.NewRPC()
-	for _, entry := range proposed.V.SAT.Entries {
+	if m.Admins() != nil {
+		return newSigningRequest(ctx, m, &signingRequest{
+			Approvals: node.Approvals{Subject: node.ApprovalSubject{
+				Action:      node.ActionApproveSpec,
+				Revision:    proposed.V.Revision,
+				ContentHash: proposed.V.Hash().ToHex(),
+			}},
+			Spec: proposed.V,
+		})
+	}
+	return doApprove(m, proposed.V, nil)
+}
+
+// doApprove tell nodes the spec is approved, along with approvals of admins if configured
+func doApprove(m *mod.Model, s *spec.Spec, approvals *node.Approvals) error {
+	rpc := node.NewRPC().WithApprovals(approvals)
+	for _, entry := range s.SAT.Entries {
 		rpc := rpc.WithAddr(entry.Addr).WithIdentity(m.Identity(), entry.ID)
-		if err := rpc.ApproveSpec(proposed.V.Revision); err != nil {
+		if err := rpc.ApproveSpec(s.Revision); err != nil {
 			return err
 		}
 	}
-	return m.SaveSpec(mod.StageApproved, *proposed.V)
+	return m.SaveSpec(mod.StageApproved, *s)
 }
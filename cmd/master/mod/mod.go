@@ -6,10 +6,12 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/vechain/solidb/cmd/master/draft"
 	"github.com/vechain/solidb/crypto"
+	"github.com/vechain/solidb/node"
 	"github.com/vechain/solidb/spec"
 	"github.com/vechain/solidb/utils/fpath"
 	yaml "gopkg.in/yaml.v2"
@@ -32,23 +34,36 @@ func makeStageFileName(stage string) string {
 
 type mainFileData struct {
 	Key string
+	// PendingKey new key of rotation not yet completed
+	PendingKey string `yaml:",omitempty"`
+	// Pending rotation not yet completed
+	Pending *node.KeyRotation `yaml:",omitempty"`
+	// Rotations completed rotations of master key
+	Rotations []node.KeyRotation `yaml:",omitempty"`
+	// Admins admin set in effect
+	Admins *node.AdminSet `yaml:",omitempty"`
 }
 
 // Model manages files of cluster master
 type Model struct {
 	dir      string
 	identity *crypto.Identity
+	// rotations completed rotations, and the pending one
+	rotations       []node.KeyRotation
+	pendingIdentity *crypto.Identity
+	pending         *node.KeyRotation
+	admins          *node.AdminSet
 
 	draft *draft.Draft
 }
 
 // New create a new model instance
-func New(dir string, replicas int) (*Model, error) {
+func New(dir string, replicas int, keyType crypto.KeyType) (*Model, error) {
 	draft, err := draft.New(replicas)
 	if err != nil {
 		return nil, err
 	}
-	identity, err := crypto.GenerateIdentity()
+	identity, err := crypto.GenerateIdentityOfType(keyType)
 	if err != nil {
 		return nil, errors.Wrap(err, "new model")
 	}
@@ -74,6 +89,16 @@ func Load(dir string) (*Model, error) {
 	if err != nil {
 		return nil, err
 	}
+	var pendingIdentity *crypto.Identity
+	if md.Pending != nil {
+		key, err := hex.DecodeString(md.PendingKey)
+		if err != nil {
+			return nil, err
+		}
+		if pendingIdentity, err = crypto.NewIdentity(key); err != nil {
+			return nil, err
+		}
+	}
 
 	draftFilePath := filepath.Join(dir, draftFileName)
 	data, err := ioutil.ReadFile(draftFilePath)
@@ -86,9 +111,13 @@ func Load(dir string) (*Model, error) {
 	}
 
 	m := Model{
-		dir:      dir,
-		identity: identity,
-		draft:    draft,
+		dir:             dir,
+		identity:        identity,
+		rotations:       md.Rotations,
+		pendingIdentity: pendingIdentity,
+		pending:         md.Pending,
+		admins:          md.Admins,
+		draft:           draft,
 	}
 
 	return &m, nil
@@ -123,23 +152,26 @@ func loadMainFile(dir string) (*mainFileData, error) {
 }
 
 func (m *Model) saveMainFile() error {
+	md := mainFileData{
+		Key:       hex.EncodeToString(m.identity.PrivateKey()),
+		Pending:   m.pending,
+		Rotations: m.rotations,
+		Admins:    m.admins,
+	}
+	if m.pendingIdentity != nil {
+		md.PendingKey = hex.EncodeToString(m.pendingIdentity.PrivateKey())
+	}
+	data, err := yaml.Marshal(&md)
+	if err != nil {
+		return err
+	}
+	// replaced atomically, to never lose the key
 	mainFilePath := filepath.Join(m.dir, mainFileName)
-	if exists, err := fpath.PathExists(mainFilePath); err != nil {
+	tmpPath := mainFilePath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
 		return err
-	} else if !exists {
-		md := mainFileData{
-			Key: hex.EncodeToString(m.identity.PrivateKey()),
-		}
-		data, err := yaml.Marshal(&md)
-		if err != nil {
-			return err
-		}
-
-		if err := ioutil.WriteFile(mainFilePath, data, 0600); err != nil {
-			return err
-		}
 	}
-	return nil
+	return os.Rename(tmpPath, mainFilePath)
 }
 
 // Draft returns draft of cluster
@@ -152,6 +184,68 @@ func (m *Model) Identity() *crypto.Identity {
 	return m.identity
 }
 
+// ClusterID returns ID of cluster, which is ID of the first master key
+func (m *Model) ClusterID() string {
+	if len(m.rotations) > 0 {
+		return m.rotations[0].OldID
+	}
+	return m.identity.ID()
+}
+
+// Rotations returns completed rotations of master key
+func (m *Model) Rotations() []node.KeyRotation {
+	return m.rotations
+}
+
+// BeginRotation returns the pending rotation, or create one with a new key of keyType.
+// The model should be saved before the rotation sent, so that the new key is never lost.
+func (m *Model) BeginRotation(keyType crypto.KeyType) (*node.KeyRotation, error) {
+	if m.pending != nil {
+		return m.pending, nil
+	}
+	identity, err := crypto.GenerateIdentityOfType(keyType)
+	if err != nil {
+		return nil, err
+	}
+	r := node.KeyRotation{
+		ClusterID: m.ClusterID(),
+		Seq:       len(m.rotations),
+		OldID:     m.identity.ID(),
+		NewID:     identity.ID(),
+		Time:      time.Now().Unix(),
+	}
+	if len(m.rotations) > 0 {
+		h := m.rotations[len(m.rotations)-1].Hash()
+		r.PrevHash = h.ToHex()
+	}
+	if err := r.Sign(m.identity, identity); err != nil {
+		return nil, err
+	}
+	m.pendingIdentity, m.pending = identity, &r
+	return &r, nil
+}
+
+// CompleteRotation replace master key by the pending one
+func (m *Model) CompleteRotation() error {
+	if m.pending == nil {
+		return errors.New("no pending rotation")
+	}
+	m.identity = m.pendingIdentity
+	m.rotations = append(m.rotations, *m.pending)
+	m.pendingIdentity, m.pending = nil, nil
+	return nil
+}
+
+// Admins returns admin set in effect, nil if not configured
+func (m *Model) Admins() *node.AdminSet {
+	return m.admins
+}
+
+// SetAdmins set admin set in effect
+func (m *Model) SetAdmins(admins *node.AdminSet) {
+	m.admins = admins
+}
+
 // Save save model state into files
 func (m *Model) Save() error {
 	if err := m.saveMainFile(); err != nil {
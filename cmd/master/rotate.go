@@ -0,0 +1,92 @@
+package master
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/cmd/master/mod"
+	"github.com/vechain/solidb/crypto"
+	"github.com/vechain/solidb/node"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// rotateKey replace master key on all nodes.
+// The new key is saved before sent, and an interrupted rotation is resumed by running again.
+func rotateKey(ctx *cli.Context) error {
+	m, err := mod.Current()
+	if err != nil {
+		return err
+	}
+	keyType := m.Identity().KeyType()
+	if ctx.IsSet(keyTypeFlag.Name) {
+		if keyType, err = crypto.ParseKeyType(ctx.String(keyTypeFlag.Name)); err != nil {
+			return err
+		}
+	}
+	rotation, err := m.BeginRotation(keyType)
+	if err != nil {
+		return err
+	}
+	if err := m.Save(); err != nil {
+		return err
+	}
+
+	nodeLocs, err := allNodeLocs(m)
+	if err != nil {
+		return err
+	}
+	// the whole chain is sent, so that nodes lagging behind catch up
+	rotations := append(append([]node.KeyRotation(nil), m.Rotations()...), *rotation)
+	rpc := node.NewRPC()
+	failed := 0
+	for _, loc := range nodeLocs {
+		err := rpc.WithAddr(loc.addr).WithIdentity(m.Identity(), loc.id).RotateMaster(rotations)
+		if err != nil {
+			failed++
+			fmt.Printf("%s\t%s\t%v\n", crypto.AbbrevID(loc.id), loc.addr, err)
+			continue
+		}
+		fmt.Printf("%s\t%s\trotated\n", crypto.AbbrevID(loc.id), loc.addr)
+	}
+	if failed > 0 {
+		return errors.Errorf("%d nodes failed, run again to resume", failed)
+	}
+
+	if err := m.CompleteRotation(); err != nil {
+		return err
+	}
+	if err := m.Save(); err != nil {
+		return err
+	}
+	fmt.Println("master ID:", m.Identity().ID())
+	return nil
+}
+
+// allNodeLocs returns nodes in draft, proposed and approved specs
+func allNodeLocs(m *mod.Model) ([]nodeLoc, error) {
+	var (
+		locs []nodeLoc
+		seen = make(map[string]bool)
+	)
+	add := func(id, addr string) {
+		if !seen[id] {
+			seen[id] = true
+			locs = append(locs, nodeLoc{id: id, addr: addr})
+		}
+	}
+	for _, n := range m.Draft().Nodes {
+		add(n.ID, n.Addr)
+	}
+	for _, stage := range []string{mod.StageProposed, mod.StageApproved} {
+		s, err := m.LoadSpec(stage)
+		if err != nil {
+			return nil, err
+		}
+		if s.V != nil {
+			for _, entry := range s.V.SAT.Entries {
+				add(entry.ID, entry.Addr)
+			}
+		}
+	}
+	return locs, nil
+}
@@ -3,6 +3,7 @@ package master
 import (
 	"fmt"
 
+	"github.com/vechain/solidb/cmd/master/mod"
 	"github.com/vechain/solidb/node"
 )
 
@@ -38,12 +39,12 @@ type nodeLoc struct {
 	addr string
 }
 
-func queryNodeStatus(nodeLocs []nodeLoc) chan nodeStatus {
+func queryNodeStatus(m *mod.Model, nodeLocs []nodeLoc) chan nodeStatus {
 	c := make(chan nodeStatus)
 	go func() {
 		rpc := node.NewRPC()
 		for _, loc := range nodeLocs {
-			status, err := rpc.WithAddr(loc.addr).GetStatus()
+			status, err := rpc.WithAddr(loc.addr).WithSigner(m, loc.id).GetStatus()
 			c <- nodeStatus{
 				status: status,
 				err:    err,
@@ -54,12 +55,12 @@ func queryNodeStatus(nodeLocs []nodeLoc) chan nodeStatus {
 	return c
 }
 
-func queryNodeSyncStatus(nodeLocs []nodeLoc, revision int) chan nodeSyncStatus {
+func queryNodeSyncStatus(m *mod.Model, nodeLocs []nodeLoc, revision int) chan nodeSyncStatus {
 	c := make(chan nodeSyncStatus)
 	go func() {
 		rpc := node.NewRPC()
 		for _, loc := range nodeLocs {
-			status, err := rpc.WithAddr(loc.addr).GetSyncStatus(revision)
+			status, err := rpc.WithAddr(loc.addr).WithSigner(m, loc.id).GetSyncStatus(revision)
 			c <- nodeSyncStatus{
 				syncStatus: status,
 				err:        err,
@@ -0,0 +1,151 @@
+package node
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/backup"
+	"github.com/vechain/solidb/kv"
+	"github.com/vechain/solidb/node"
+	"github.com/vechain/solidb/utils/fpath"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var (
+	backupAddrFlag = cli.StringFlag{
+		Name:  "addr",
+		Usage: "loopback IP:port of running node",
+		Value: fmt.Sprintf("127.0.0.1:%d", DefaultHTTPPort),
+	}
+	backupToFlag = cli.StringFlag{
+		Name:  "to",
+		Usage: "path of archive file to write",
+	}
+	restoreFromFlag = cli.StringFlag{
+		Name:  "from",
+		Usage: "path of archive file to read",
+	}
+)
+
+// backupTokenFile name of file in node dir, holding token to authorize backup
+const backupTokenFile = "backup.token"
+
+// writeBackupToken generate a random backup token into node dir, readable by owner only.
+// A new token is generated on each start.
+func writeBackupToken(dataDir string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	path := filepath.Join(dataDir, backupTokenFile)
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := ioutil.WriteFile(tmp, []byte(token), 0600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// backupNode write archive of a running node's store into file
+func backupNode(ctx *cli.Context) error {
+	to := ctx.String(backupToFlag.Name)
+	if to == "" {
+		return errors.New("archive path required")
+	}
+	dataDir, err := nodeDir(ctx)
+	if err != nil {
+		return err
+	}
+	token, err := ioutil.ReadFile(filepath.Join(dataDir, backupTokenFile))
+	if err != nil {
+		return errors.Wrap(err, "read backup token")
+	}
+	body, err := node.NewRPC().WithAddr(ctx.String(backupAddrFlag.Name)).Backup(strings.TrimSpace(string(token)))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// write to temp file, so that a failed backup leaves nothing.
+	// It's readable by owner only, as it contains the node key and plaintext values.
+	tmp := to + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	// verify the archive while receiving
+	result, err := backup.Verify(io.TeeReader(body, f))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, to); err != nil {
+		return err
+	}
+	fmt.Printf("%d pairs, %d blobs archived to %s\n", result.PairCount, result.BlobCount, to)
+	return nil
+}
+
+// restoreNode rebuild store from archive
+func restoreNode(ctx *cli.Context) error {
+	from := ctx.String(restoreFromFlag.Name)
+	if from == "" {
+		return errors.New("archive path required")
+	}
+	dataDir, err := nodeDir(ctx)
+	if err != nil {
+		return err
+	}
+	storePath := filepath.Join(dataDir, "store")
+	if exists, err := fpath.PathExists(storePath); err != nil {
+		return err
+	} else if exists {
+		return errors.Errorf("store %s already exists", storePath)
+	}
+
+	encryption, err := encryptionOptions(ctx)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	store, err := kv.NewStore(storePath, kv.Options{
+		Engine:     ctx.String(engineFlag.Name),
+		Encryption: encryption,
+	})
+	if err != nil {
+		return err
+	}
+	result, err := backup.Restore(f, store)
+	store.Close()
+	if err != nil {
+		os.RemoveAll(storePath)
+		return err
+	}
+	fmt.Printf("%d pairs, %d blobs restored to %s\n", result.PairCount, result.BlobCount, storePath)
+	return nil
+}
@@ -2,19 +2,26 @@ package node
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/vechain/solidb/blobio"
 	"github.com/vechain/solidb/broker"
+	"github.com/vechain/solidb/crypto"
 	"github.com/vechain/solidb/kv"
 	"github.com/vechain/solidb/node"
+	"github.com/vechain/solidb/schema"
 	"github.com/vechain/solidb/specmgr"
 	"github.com/vechain/solidb/utils/fpath"
 	cli "gopkg.in/urfave/cli.v1"
@@ -33,6 +40,44 @@ var (
 				bindFlag,
 				dirFlag,
 				devFlag,
+				codecFlag,
+				engineFlag,
+				durabilityFlag,
+				commitDelayFlag,
+				scrubRateFlag,
+				nodeCacheFlag,
+				brokerCacheFlag,
+				brokerCacheTTLFlag,
+				maxEncryptedObjectFlag,
+				capacityFlag,
+				highWatermarkFlag,
+				encryptionKeyFileFlag,
+				encryptionPassphraseFlag,
+				keyTypeFlag,
+			},
+			Subcommands: []cli.Command{
+				{
+					Action: backupNode,
+					Name:   "backup",
+					Usage:  "archive store of running node into file",
+					Flags: []cli.Flag{
+						backupAddrFlag,
+						backupToFlag,
+						dirFlag,
+					},
+				},
+				{
+					Action: restoreNode,
+					Name:   "restore",
+					Usage:  "rebuild store from archive, the node should be stopped",
+					Flags: []cli.Flag{
+						dirFlag,
+						engineFlag,
+						encryptionKeyFileFlag,
+						encryptionPassphraseFlag,
+						restoreFromFlag,
+					},
+				},
 			},
 		},
 	}
@@ -50,8 +95,103 @@ var (
 		Usage:  "if set, node will use mem store",
 		Hidden: true,
 	}
+	codecFlag = cli.StringFlag{
+		Name:  "codec",
+		Usage: "codec to compress stored blobs (none|flate)",
+		Value: "flate",
+	}
+	engineFlag = cli.StringFlag{
+		Name:  "engine",
+		Usage: "storage engine, can't be changed once store created (leveldb|segment)",
+		Value: kv.EngineLevelDB,
+	}
+	durabilityFlag = cli.StringFlag{
+		Name:  "durability",
+		Usage: "durability of writes (sync|group|async)",
+		Value: kv.DurabilitySync,
+	}
+	commitDelayFlag = cli.DurationFlag{
+		Name:  "commit-delay",
+		Usage: "max delay of writes to be grouped, for group durability",
+		Value: 2 * time.Millisecond,
+	}
+	scrubRateFlag = cli.IntFlag{
+		Name:  "scrub-rate",
+		Usage: "max bytes per second read to verify stored blobs in background, 0 to disable",
+		Value: 4 << 20,
+	}
+	nodeCacheFlag = cli.IntFlag{
+		Name:  "node-cache",
+		Usage: "size in MB of blobs cached in memory by node, 0 to disable",
+		Value: 64,
+	}
+	brokerCacheFlag = cli.IntFlag{
+		Name:  "broker-cache",
+		Usage: "size in MB of blobs cached in memory by broker, 0 to disable",
+		Value: 64,
+	}
+	maxEncryptedObjectFlag = cli.Int64Flag{
+		Name:  "max-encrypted-object",
+		Usage: "max size in MB of object uploaded with convergent encryption, which is spooled to disk",
+		Value: broker.DefaultMaxEncryptedObjectSize >> 20,
+	}
+	brokerCacheTTLFlag = cli.DurationFlag{
+		Name:  "broker-cache-ttl",
+		Usage: "max duration a blob is served from broker cache, which may outlive the blob deleted by GC",
+		Value: 10 * time.Minute,
+	}
+	capacityFlag = cli.Int64Flag{
+		Name:  "capacity",
+		Usage: "max size in MB of store, 0 for unlimited",
+	}
+	highWatermarkFlag = cli.Float64Flag{
+		Name:  "high-watermark",
+		Usage: "ratio of capacity, beyond which writes are rejected",
+		Value: 0.95,
+	}
+	keyTypeFlag = cli.StringFlag{
+		Name:  "key-type",
+		Usage: "type of node key when generated, no effect on existing key (p256|ed25519)",
+		Value: string(crypto.KeyTypeP256),
+	}
+	encryptionKeyFileFlag = cli.StringFlag{
+		Name:  "encryption-key-file",
+		Usage: "file of 32 bytes key, raw or hex encoded, to encrypt store at rest",
+	}
+	encryptionPassphraseFlag = cli.StringFlag{
+		Name:   "encryption-passphrase",
+		Usage:  "passphrase to encrypt store at rest",
+		EnvVar: "SOLIDB_ENCRYPTION_PASSPHRASE",
+	}
 )
 
+// encryptionOptions returns nil if encryption not enabled
+func encryptionOptions(ctx *cli.Context) (*kv.EncryptionOptions, error) {
+	keyFile := ctx.String(encryptionKeyFileFlag.Name)
+	passphrase := ctx.String(encryptionPassphraseFlag.Name)
+	if keyFile == "" && passphrase == "" {
+		return nil, nil
+	}
+	if keyFile != "" && passphrase != "" {
+		return nil, errors.New("encryption key file and passphrase are exclusive")
+	}
+	if passphrase != "" {
+		return &kv.EncryptionOptions{Passphrase: passphrase}, nil
+	}
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 32 {
+		return &kv.EncryptionOptions{Key: data}, nil
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("encryption key file should contain 32 bytes key, or 64 hex chars")
+	}
+	return &kv.EncryptionOptions{Key: key}, nil
+}
+
 func nodeDir(ctx *cli.Context) (string, error) {
 	dir := ctx.String(dirFlag.Name)
 	if dir == "" {
@@ -85,7 +225,15 @@ func startNode(ctx *cli.Context) error {
 	}
 	log.Println("HTTP server listening on", listener.Addr())
 
-	var store kv.Store
+	if err := blobio.SetWriteCodec(ctx.String(codecFlag.Name)); err != nil {
+		return err
+	}
+
+	var (
+		store       kv.Store
+		storePath   string
+		backupToken string
+	)
 	if ctx.IsSet(devFlag.Name) {
 		store, err = kv.NewMemStore(kv.Options{CacheSize: 128})
 		if err != nil {
@@ -98,22 +246,49 @@ func startNode(ctx *cli.Context) error {
 			return err
 		}
 		log.Println("Location:", dataDir)
-		storePath := filepath.Join(dataDir, "store")
+		encryption, err := encryptionOptions(ctx)
+		if err != nil {
+			return err
+		}
+		storePath = filepath.Join(dataDir, "store")
 		store, err = kv.NewStore(storePath, kv.Options{
 			CacheSize:              128,
 			OpenFilesCacheCapacity: 32,
+			Engine:                 ctx.String(engineFlag.Name),
+			Durability:             ctx.String(durabilityFlag.Name),
+			MaxCommitDelay:         ctx.Duration(commitDelayFlag.Name),
+			Encryption:             encryption,
 		})
 		if err != nil {
 			return err
 		}
+		if backupToken, err = writeBackupToken(dataDir); err != nil {
+			store.Close()
+			return err
+		}
 	}
 
 	defer func() {
 		store.Close()
 		log.Println("store closed")
 	}()
+	if err := schema.Migrate(store); err != nil {
+		return err
+	}
+	keyType, err := crypto.ParseKeyType(ctx.String(keyTypeFlag.Name))
+	if err != nil {
+		return err
+	}
 	specMgr := specmgr.New(store)
-	n, err := node.New(store, specMgr)
+	n, err := node.New(store, specMgr, node.Options{
+		ScrubRate:     ctx.Int(scrubRateFlag.Name),
+		CacheSize:     ctx.Int(nodeCacheFlag.Name) << 20,
+		StoreDir:      storePath,
+		Capacity:      ctx.Int64(capacityFlag.Name) << 20,
+		HighWatermark: ctx.Float64(highWatermarkFlag.Name),
+		KeyType:       keyType,
+		BackupToken:   backupToken,
+	})
 	if err != nil {
 		return err
 	}
@@ -122,7 +297,12 @@ func startNode(ctx *cli.Context) error {
 	n.Start()
 	defer n.Shutdown()
 
-	brk := broker.New(store, specMgr)
+	brk := broker.New(store, specMgr, n, broker.Options{
+		CacheSize: ctx.Int(brokerCacheFlag.Name) << 20,
+		CacheTTL:  ctx.Duration(brokerCacheTTLFlag.Name),
+
+		MaxEncryptedObjectSize: ctx.Int64(maxEncryptedObjectFlag.Name) << 20,
+	})
 	defer brk.Shutdown()
 
 	mux := http.NewServeMux()
@@ -143,7 +323,7 @@ func serveHTTP(listener net.Listener, handler http.Handler) error {
 		}
 	}()
 
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit,
 		syscall.SIGINT, syscall.SIGTERM,
 		syscall.SIGHUP, syscall.SIGKILL,
@@ -3,6 +3,7 @@ package crypto
 import (
 	"encoding/hex"
 	"encoding/json"
+	"hash"
 
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/blake2b"
@@ -59,3 +60,10 @@ func HexToHash(str string) (*Hash, error) {
 func HashSum(data []byte) (hash Hash) {
 	return blake2b.Sum256(data)
 }
+
+// NewHasher returns a hash.Hash computing the same hash as HashSum.
+// It's useful to compute hash while data arrives.
+func NewHasher() hash.Hash {
+	h, _ := blake2b.New256(nil)
+	return h
+}
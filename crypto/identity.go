@@ -10,11 +10,47 @@ import (
 	"math/big"
 
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
 )
 
 var curve = elliptic.P256()
 
-type signature struct {
+// KeyType type of identity key
+type KeyType string
+
+// supported key types
+const (
+	KeyTypeP256    KeyType = "p256"
+	KeyTypeEd25519 KeyType = "ed25519"
+)
+
+// ParseKeyType parse key type from string
+func ParseKeyType(str string) (KeyType, error) {
+	switch t := KeyType(str); t {
+	case KeyTypeP256, KeyTypeEd25519:
+		return t, nil
+	}
+	return "", errors.Errorf("unsupported key type %s", str)
+}
+
+const (
+	p256KeyLen = 32
+	// p256PubLen length of uncompressed P-256 public key
+	p256PubLen = 65
+	p256SigLen = 64
+)
+
+// sigVersion version of binary signature envelope
+const sigVersion = 1
+
+// algorithms in binary signature envelope
+const (
+	sigAlgP256    = 1
+	sigAlgEd25519 = 2
+)
+
+// legacySignature JSON signature produced by older versions, only verified
+type legacySignature struct {
 	R   *big.Int
 	S   *big.Int
 	Pub []byte
@@ -26,43 +62,81 @@ func publicKeyToID(pub []byte) string {
 	return hex.EncodeToString(hash[12:])
 }
 
-// Identity wrap ECDSA private key to identify some one.
+// Identity wrap P-256 ECDSA or Ed25519 private key to identify some one.
 type Identity struct {
-	privKey *ecdsa.PrivateKey
+	p256Key    *ecdsa.PrivateKey
+	ed25519Key ed25519.PrivateKey
 
 	cachedID string
 }
 
-// GenerateIdentity generate a new identity
+// GenerateIdentity generate a new P-256 identity
 func GenerateIdentity() (*Identity, error) {
-	privKey, err := ecdsa.GenerateKey(curve, rand.Reader)
-	if err != nil {
-		return nil, errors.Wrap(err, "generate identity")
+	return GenerateIdentityOfType(KeyTypeP256)
+}
+
+// GenerateIdentityOfType generate a new identity of key type
+func GenerateIdentityOfType(keyType KeyType) (*Identity, error) {
+	switch keyType {
+	case KeyTypeP256:
+		privKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "generate identity")
+		}
+		return &Identity{p256Key: privKey}, nil
+	case KeyTypeEd25519:
+		_, privKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "generate identity")
+		}
+		return &Identity{ed25519Key: privKey}, nil
 	}
-	return &Identity{privKey: privKey}, nil
+	return nil, errors.Errorf("generate identity: unsupported key type %s", keyType)
 }
 
 // NewIdentity create identity from private key.
+// Key type is told by length of private key.
 func NewIdentity(privKey []byte) (*Identity, error) {
-	if len(privKey) != curve.Params().BitSize/8 {
-		return nil, errors.New("invalid private key length")
+	switch len(privKey) {
+	case p256KeyLen:
+		priv := ecdsa.PrivateKey{}
+		priv.Curve = curve
+		priv.D = new(big.Int)
+		priv.D.SetBytes(privKey)
+		priv.PublicKey.X, priv.PublicKey.Y = curve.ScalarBaseMult(privKey)
+		return &Identity{p256Key: &priv}, nil
+	case ed25519.PrivateKeySize:
+		return &Identity{ed25519Key: ed25519.NewKeyFromSeed(privKey[:ed25519.SeedSize])}, nil
 	}
-	priv := ecdsa.PrivateKey{}
-	priv.Curve = curve
-	priv.D = new(big.Int)
-	priv.D.SetBytes(privKey)
-	priv.PublicKey.X, priv.PublicKey.Y = curve.ScalarBaseMult(privKey)
-	return &Identity{privKey: &priv}, nil
+	return nil, errors.New("invalid private key length")
+}
+
+// KeyType returns type of key
+func (identity *Identity) KeyType() KeyType {
+	if identity.ed25519Key != nil {
+		return KeyTypeEd25519
+	}
+	return KeyTypeP256
 }
 
 // PrivateKey returns private key in bytes.
 func (identity *Identity) PrivateKey() []byte {
-	return identity.privKey.D.Bytes()
+	if identity.ed25519Key != nil {
+		return append([]byte(nil), identity.ed25519Key...)
+	}
+	// left padded, since D may be shorter
+	d := identity.p256Key.D.Bytes()
+	key := make([]byte, p256KeyLen)
+	copy(key[p256KeyLen-len(d):], d)
+	return key
 }
 
 // PublicKey returns public key in bytes.
 func (identity *Identity) publicKey() []byte {
-	return elliptic.Marshal(curve, identity.privKey.X, identity.privKey.Y)
+	if identity.ed25519Key != nil {
+		return identity.ed25519Key.Public().(ed25519.PublicKey)
+	}
+	return elliptic.Marshal(curve, identity.p256Key.X, identity.p256Key.Y)
 }
 
 // ID returns ID of identity in string.
@@ -78,30 +152,106 @@ func (identity *Identity) ID() string {
 }
 
 // Sign sign message hash and returns signature.
+// The signature is encoded as version(1) | alg(1) | public key | sig.
 func (identity *Identity) Sign(msgHash Hash) ([]byte, error) {
-	r, s, err := ecdsa.Sign(rand.Reader, identity.privKey, msgHash[:])
-	if err != nil {
-		return nil, errors.Wrap(err, "sign")
+	pub := identity.publicKey()
+	if identity.ed25519Key != nil {
+		sig := ed25519.Sign(identity.ed25519Key, msgHash[:])
+		return append(append([]byte{sigVersion, sigAlgEd25519}, pub...), sig...), nil
 	}
-	sig := signature{
-		R:   r,
-		S:   s,
-		Pub: identity.publicKey(),
-	}
-	data, err := json.Marshal(&sig)
+
+	r, s, err := ecdsa.Sign(rand.Reader, identity.p256Key, msgHash[:])
 	if err != nil {
 		return nil, errors.Wrap(err, "sign")
 	}
+	data := make([]byte, 2+p256PubLen+p256SigLen)
+	data[0], data[1] = sigVersion, sigAlgP256
+	copy(data[2:], pub)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(data[2+p256PubLen+p256SigLen/2-len(rb):], rb)
+	copy(data[len(data)-len(sb):], sb)
 	return data, nil
 }
 
 // RecoverID recover ID from message hash and signature.
+// Both binary envelope and legacy JSON signature are accepted.
 func RecoverID(msgHash Hash, sig []byte) (string, error) {
-	var sigt signature
+	if len(sig) > 0 && sig[0] == '{' {
+		return recoverIDLegacy(msgHash, sig)
+	}
+	if len(sig) < 2 {
+		return "", errors.New("recover id: signature too short")
+	}
+	if sig[0] != sigVersion {
+		return "", errors.Errorf("recover id: unsupported signature version %d", sig[0])
+	}
+	body := sig[2:]
+	switch sig[1] {
+	case sigAlgP256:
+		if len(body) != p256PubLen+p256SigLen {
+			return "", errors.New("recover id: invalid signature length")
+		}
+		pub := body[:p256PubLen]
+		x, y := elliptic.Unmarshal(curve, pub)
+		if x == nil {
+			return "", errors.New("recover id: invalid public key")
+		}
+		r := new(big.Int).SetBytes(body[p256PubLen : p256PubLen+p256SigLen/2])
+		s := new(big.Int).SetBytes(body[p256PubLen+p256SigLen/2:])
+		if !ecdsa.Verify(&ecdsa.PublicKey{X: x, Y: y, Curve: curve}, msgHash[:], r, s) {
+			return "", errors.New("recover id: verify signature failed")
+		}
+		return publicKeyToID(pub), nil
+	case sigAlgEd25519:
+		if len(body) != ed25519.PublicKeySize+ed25519.SignatureSize {
+			return "", errors.New("recover id: invalid signature length")
+		}
+		pub := body[:ed25519.PublicKeySize]
+		if !ed25519.Verify(ed25519.PublicKey(pub), msgHash[:], body[ed25519.PublicKeySize:]) {
+			return "", errors.New("recover id: verify signature failed")
+		}
+		return publicKeyToID(pub), nil
+	}
+	return "", errors.Errorf("recover id: unsupported signature algorithm %d", sig[1])
+}
+
+// SignerID returns ID of public key carried by signature, which is not verified.
+// It allows to reject unknown signers before the signed message is at hand.
+func SignerID(sig []byte) (string, error) {
+	if len(sig) > 0 && sig[0] == '{' {
+		var sigt legacySignature
+		if err := json.Unmarshal(sig, &sigt); err != nil {
+			return "", errors.Wrap(err, "signer id")
+		}
+		return publicKeyToID(sigt.Pub), nil
+	}
+	if len(sig) < 2 || sig[0] != sigVersion {
+		return "", errors.New("signer id: invalid signature")
+	}
+	pubLen := 0
+	switch sig[1] {
+	case sigAlgP256:
+		pubLen = p256PubLen
+	case sigAlgEd25519:
+		pubLen = ed25519.PublicKeySize
+	default:
+		return "", errors.Errorf("signer id: unsupported signature algorithm %d", sig[1])
+	}
+	if len(sig) < 2+pubLen {
+		return "", errors.New("signer id: signature too short")
+	}
+	return publicKeyToID(sig[2 : 2+pubLen]), nil
+}
+
+func recoverIDLegacy(msgHash Hash, sig []byte) (string, error) {
+	var sigt legacySignature
 	if err := json.Unmarshal(sig, &sigt); err != nil {
 		return "", errors.Wrap(err, "recover id")
 	}
 	x, y := elliptic.Unmarshal(curve, sigt.Pub)
+	if x == nil || sigt.R == nil || sigt.S == nil {
+		return "", errors.New("recover id: invalid signature")
+	}
 	if !ecdsa.Verify(&ecdsa.PublicKey{X: x, Y: y, Curve: curve}, msgHash[:], sigt.R, sigt.S) {
 		return "", errors.New("recover id: verify signature failed")
 	}
@@ -1,6 +1,11 @@
 package crypto_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,6 +32,74 @@ func TestSign(t *testing.T) {
 	assert.Equal(id, i1.ID())
 }
 
+func TestSignerID(t *testing.T) {
+	assert := assert.New(t)
+
+	hash := HashSum([]byte("hello world"))
+	for _, keyType := range []KeyType{KeyTypeP256, KeyTypeEd25519} {
+		i1, _ := GenerateIdentityOfType(keyType)
+		sig, _ := i1.Sign(hash)
+		id, err := SignerID(sig)
+		assert.Nil(err)
+		assert.Equal(i1.ID(), id)
+
+		_, err = SignerID(sig[:10])
+		assert.NotNil(err)
+	}
+}
+
+func TestEd25519(t *testing.T) {
+	assert := assert.New(t)
+
+	i1, err := GenerateIdentityOfType(KeyTypeEd25519)
+	assert.Nil(err)
+	assert.Equal(KeyTypeEd25519, i1.KeyType())
+	i2, _ := NewIdentity(i1.PrivateKey())
+	assert.Equal(i1.ID(), i2.ID())
+	assert.Equal(KeyTypeEd25519, i2.KeyType())
+
+	hash := HashSum([]byte("hello world"))
+	sig, _ := i2.Sign(hash)
+	id, err := RecoverID(hash, sig)
+	assert.Nil(err)
+	assert.Equal(i1.ID(), id)
+
+	sig[len(sig)-1]++
+	_, err = RecoverID(hash, sig)
+	assert.NotNil(err)
+
+	_, err = RecoverID(HashSum([]byte("hello")), sig)
+	assert.NotNil(err)
+}
+
+func TestLegacySignature(t *testing.T) {
+	assert := assert.New(t)
+
+	i1, _ := GenerateIdentity()
+	hash := HashSum([]byte("hello world"))
+
+	// JSON signature produced by older versions
+	key := i1.PrivateKey()
+	priv := ecdsa.PrivateKey{D: new(big.Int).SetBytes(key)}
+	priv.Curve = elliptic.P256()
+	priv.X, priv.Y = priv.Curve.ScalarBaseMult(key)
+	r, s, _ := ecdsa.Sign(rand.Reader, &priv, hash[:])
+	sig, _ := json.Marshal(map[string]interface{}{
+		"R":   r,
+		"S":   s,
+		"Pub": elliptic.Marshal(priv.Curve, priv.X, priv.Y),
+	})
+
+	id, err := RecoverID(hash, sig)
+	assert.Nil(err)
+	assert.Equal(i1.ID(), id)
+
+	newSig, _ := i1.Sign(hash)
+	assert.True(len(newSig) < len(sig))
+	id, _ = RecoverID(hash, newSig)
+	assert.Equal(i1.ID(), id)
+}
+
 func BenchmarkSign(b *testing.B) {
 	hash := HashSum([]byte("hello world"))
 	id, _ := GenerateIdentity()
@@ -0,0 +1,172 @@
+package kv
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// durability modes of writes
+const (
+	// DurabilitySync each write is synced to disk before acknowledged
+	DurabilitySync = "sync"
+	// DurabilityGroup concurrent writes are merged and synced together, delayed no more than MaxCommitDelay
+	DurabilityGroup = "group"
+	// DurabilityAsync writes are acknowledged without sync, and recent writes may be lost on system crash
+	DurabilityAsync = "async"
+)
+
+const (
+	defaultMaxCommitDelay = 2 * time.Millisecond
+	// max count of batches merged into a group
+	maxGroupLen = 1024
+)
+
+var (
+	syncWriteOpt  = &opt.WriteOptions{Sync: true}
+	asyncWriteOpt = &opt.WriteOptions{Sync: false}
+
+	errCommitterClosed = errors.New("committer closed")
+)
+
+type commitRequest struct {
+	batch *leveldb.Batch
+	done  chan error
+}
+
+// committer writes batches to leveldb according to durability mode
+type committer struct {
+	db         *leveldb.DB
+	durability string
+	maxDelay   time.Duration
+	// preSync is called before synced writes, to make data referenced by the batch durable
+	preSync func() error
+
+	// mu is held while writing to db, so that check-and-write can be done atomically by holding it
+	mu sync.Mutex
+
+	closeMu sync.RWMutex
+	closed  bool
+	reqs    chan *commitRequest
+	wg      sync.WaitGroup
+}
+
+func newCommitter(db *leveldb.DB, options Options, preSync func() error) (*committer, error) {
+	c := &committer{
+		db:         db,
+		durability: options.Durability,
+		maxDelay:   options.MaxCommitDelay,
+		preSync:    preSync,
+	}
+	switch c.durability {
+	case "":
+		c.durability = DurabilitySync
+	case DurabilitySync, DurabilityAsync:
+	case DurabilityGroup:
+		if c.maxDelay <= 0 {
+			c.maxDelay = defaultMaxCommitDelay
+		}
+		c.reqs = make(chan *commitRequest)
+		c.wg.Add(1)
+		go c.loop()
+	default:
+		return nil, errors.Errorf("unknown durability %s", options.Durability)
+	}
+	return c, nil
+}
+
+// commit write the batch, and returns once it's durable as durability mode promised.
+func (c *committer) commit(batch *leveldb.Batch) error {
+	switch c.durability {
+	case DurabilityAsync:
+		return c.write(batch, false)
+	case DurabilityGroup:
+		req := &commitRequest{
+			batch: batch,
+			done:  make(chan error, 1),
+		}
+		c.closeMu.RLock()
+		if c.closed {
+			c.closeMu.RUnlock()
+			return errCommitterClosed
+		}
+		c.reqs <- req
+		c.closeMu.RUnlock()
+		return <-req.done
+	default:
+		return c.write(batch, true)
+	}
+}
+
+func (c *committer) write(batch *leveldb.Batch, sync bool) error {
+	if !sync {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.db.Write(batch, asyncWriteOpt)
+	}
+	if c.preSync != nil {
+		if err := c.preSync(); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.db.Write(batch, syncWriteOpt)
+}
+
+// loop merges concurrent requests into one synced write
+func (c *committer) loop() {
+	defer c.wg.Done()
+	for req := range c.reqs {
+		group := []*commitRequest{req}
+		timer := time.NewTimer(c.maxDelay)
+	collect:
+		for len(group) < maxGroupLen {
+			select {
+			case r, ok := <-c.reqs:
+				if !ok {
+					break collect
+				}
+				group = append(group, r)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		var err error
+		if len(group) == 1 {
+			err = c.write(req.batch, true)
+		} else {
+			merged := &leveldb.Batch{}
+			for _, r := range group {
+				if err = r.batch.Replay(merged); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				err = c.write(merged, true)
+			}
+		}
+		// every request is acknowledged with result of the shared write
+		for _, r := range group {
+			r.done <- err
+		}
+	}
+}
+
+func (c *committer) close() {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if c.reqs != nil {
+		close(c.reqs)
+		c.wg.Wait()
+	}
+}
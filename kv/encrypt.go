@@ -0,0 +1,316 @@
+package kv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// EncryptionOptions options of encryption at rest.
+// Values are encrypted by a random data key, which is wrapped by a key derived from Passphrase, or by Key.
+type EncryptionOptions struct {
+	// Passphrase to derive key encryption key
+	Passphrase string
+	// Key 32 bytes key encryption key, exclusive with Passphrase
+	Key []byte
+}
+
+// key derivation methods of key encryption key
+const (
+	kdfScrypt = "scrypt"
+	kdfNone   = "none"
+)
+
+const (
+	dataKeyLen = 32
+	scryptN    = 1 << 15
+	scryptR    = 8
+	scryptP    = 1
+)
+
+// encryptionKey key to store the wrapped data key, unencrypted
+var encryptionKey = NewTable("encryption", ".encryption").Key(nil)
+
+// encryptionRecord describes how data key is wrapped
+type encryptionRecord struct {
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt,omitempty"`
+	N          int    `json:"n,omitempty"`
+	R          int    `json:"r,omitempty"`
+	P          int    `json:"p,omitempty"`
+	WrappedKey []byte `json:"wrappedKey"`
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealValue encrypt value with key as additional data, so that values can't be swapped between keys.
+func sealValue(aead cipher.AEAD, key, value []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, value, key), nil
+}
+
+func openValue(aead cipher.AEAD, key, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed value too short")
+	}
+	// empty rather than nil plaintext, which reads as absent
+	return aead.Open([]byte{}, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], key)
+}
+
+// keyEncryptionKey derive key encryption key as described by record
+func (r *encryptionRecord) keyEncryptionKey(options *EncryptionOptions) ([]byte, error) {
+	switch r.KDF {
+	case kdfScrypt:
+		if options.Passphrase == "" {
+			return nil, errors.New("passphrase required")
+		}
+		return scrypt.Key([]byte(options.Passphrase), r.Salt, r.N, r.R, r.P, dataKeyLen)
+	case kdfNone:
+		if len(options.Key) != dataKeyLen {
+			return nil, errors.Errorf("%d bytes key required", dataKeyLen)
+		}
+		return options.Key, nil
+	default:
+		return nil, errors.Errorf("unknown kdf %s", r.KDF)
+	}
+}
+
+func newEncryptionRecord(options *EncryptionOptions) (*encryptionRecord, []byte, error) {
+	var r encryptionRecord
+	if options.Passphrase != "" {
+		if options.Key != nil {
+			return nil, nil, errors.New("passphrase and key are exclusive")
+		}
+		r.KDF = kdfScrypt
+		r.Salt = make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, r.Salt); err != nil {
+			return nil, nil, err
+		}
+		r.N, r.R, r.P = scryptN, scryptR, scryptP
+	} else {
+		r.KDF = kdfNone
+	}
+	kek, err := r.keyEncryptionKey(options)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataKey := make([]byte, dataKeyLen)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, nil, err
+	}
+	if r.WrappedKey, err = sealValue(aead, encryptionKey, dataKey); err != nil {
+		return nil, nil, err
+	}
+	return &r, dataKey, nil
+}
+
+// openEncryption wraps store with encryption if enabled.
+// Encryption can't be enabled or disabled once store has data.
+func openEncryption(store Store, options *EncryptionOptions) (Store, error) {
+	data, err := store.Get(encryptionKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "open encryption")
+	}
+	if options == nil {
+		if data.V != nil {
+			return nil, errors.New("open encryption: store is encrypted")
+		}
+		return store, nil
+	}
+
+	var dataKey []byte
+	if data.V == nil {
+		iter := store.NewIterator(NewRange(nil, nil))
+		empty := !iter.First()
+		iter.Release()
+		if !empty {
+			return nil, errors.New("open encryption: store is unencrypted")
+		}
+		r, key, err := newEncryptionRecord(options)
+		if err != nil {
+			return nil, errors.Wrap(err, "open encryption")
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "open encryption")
+		}
+		if err := store.Put(encryptionKey, data); err != nil {
+			return nil, errors.Wrap(err, "open encryption")
+		}
+		dataKey = key
+	} else {
+		var r encryptionRecord
+		if err := json.Unmarshal(data.V, &r); err != nil {
+			return nil, errors.Wrap(err, "open encryption")
+		}
+		kek, err := r.keyEncryptionKey(options)
+		if err != nil {
+			return nil, errors.Wrap(err, "open encryption")
+		}
+		aead, err := newAEAD(kek)
+		if err != nil {
+			return nil, errors.Wrap(err, "open encryption")
+		}
+		if dataKey, err = openValue(aead, encryptionKey, r.WrappedKey); err != nil {
+			return nil, errors.New("open encryption: incorrect passphrase or key")
+		}
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "open encryption")
+	}
+	es := &encryptedStore{store, aead}
+	if compacter, ok := store.(Compacter); ok {
+		return &struct {
+			*encryptedStore
+			Compacter
+		}{es, compacter}, nil
+	}
+	return es, nil
+}
+
+// encryptedStore encrypts values by AES-GCM, keys are left as is.
+type encryptedStore struct {
+	Store
+	aead cipher.AEAD
+}
+
+func (s *encryptedStore) Get(key []byte) (*OptValue, error) {
+	return getDecrypted(s.Store, s.aead, key)
+}
+
+func (s *encryptedStore) Put(key []byte, value []byte) error {
+	sealed, err := sealValue(s.aead, key, value)
+	if err != nil {
+		return errors.Wrap(err, "encrypt")
+	}
+	return s.Store.Put(key, sealed)
+}
+
+func (s *encryptedStore) NewIterator(r *Range) Iterator {
+	return &encryptedIterator{Iterator: s.Store.NewIterator(r), aead: s.aead}
+}
+
+func (s *encryptedStore) NewBatch() Batch {
+	return &encryptedBatch{s.Store.NewBatch(), s.aead}
+}
+
+func (s *encryptedStore) NewSnapshot() (Snapshot, error) {
+	snap, err := s.Store.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedSnapshot{snap, s.aead}, nil
+}
+
+func getDecrypted(reader Reader, aead cipher.AEAD, key []byte) (*OptValue, error) {
+	value, err := reader.Get(key)
+	if err != nil || value.V == nil {
+		return value, err
+	}
+	plain, err := openValue(aead, key, value.V)
+	if err != nil {
+		return nil, errors.Wrapf(ErrCorrupt, "decrypt: %v", err)
+	}
+	return &OptValue{plain}, nil
+}
+
+type encryptedBatch struct {
+	Batch
+	aead cipher.AEAD
+}
+
+func (b *encryptedBatch) Put(key []byte, value []byte) error {
+	sealed, err := sealValue(b.aead, key, value)
+	if err != nil {
+		return errors.Wrap(err, "encrypt")
+	}
+	return b.Batch.Put(key, sealed)
+}
+
+type encryptedSnapshot struct {
+	Snapshot
+	aead cipher.AEAD
+}
+
+func (s *encryptedSnapshot) Get(key []byte) (*OptValue, error) {
+	return getDecrypted(s.Snapshot, s.aead, key)
+}
+
+func (s *encryptedSnapshot) NewIterator(r *Range) Iterator {
+	return &encryptedIterator{Iterator: s.Snapshot.NewIterator(r), aead: s.aead}
+}
+
+// encryptedIterator decrypts values, and hides the wrapped data key
+type encryptedIterator struct {
+	Iterator
+	aead cipher.AEAD
+	err  error
+}
+
+// skip move over the wrapped data key
+func (i *encryptedIterator) skip(ok bool, move func() bool) bool {
+	if i.err != nil {
+		return false
+	}
+	if ok && bytes.Equal(i.Iterator.Key(), encryptionKey) {
+		return move()
+	}
+	return ok
+}
+
+func (i *encryptedIterator) First() bool {
+	return i.skip(i.Iterator.First(), i.Iterator.Next)
+}
+
+func (i *encryptedIterator) Last() bool {
+	return i.skip(i.Iterator.Last(), i.Iterator.Prev)
+}
+
+func (i *encryptedIterator) Seek(key []byte) bool {
+	return i.skip(i.Iterator.Seek(key), i.Iterator.Next)
+}
+
+func (i *encryptedIterator) Next() bool {
+	return i.skip(i.Iterator.Next(), i.Iterator.Next)
+}
+
+func (i *encryptedIterator) Prev() bool {
+	return i.skip(i.Iterator.Prev(), i.Iterator.Prev)
+}
+
+func (i *encryptedIterator) Value() []byte {
+	plain, err := openValue(i.aead, i.Iterator.Key(), i.Iterator.Value())
+	if err != nil {
+		i.err = errors.Wrapf(ErrCorrupt, "decrypt: %v", err)
+		return nil
+	}
+	return plain
+}
+
+func (i *encryptedIterator) Error() error {
+	if i.err != nil {
+		return i.err
+	}
+	return i.Iterator.Error()
+}
@@ -0,0 +1,73 @@
+package kv_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/vechain/solidb/kv"
+)
+
+func TestEncryption(t *testing.T) {
+	assert := assert.New(t)
+	dbPath, _ := ioutil.TempDir(os.TempDir(), "db")
+	defer os.RemoveAll(dbPath)
+
+	options := Options{Encryption: &EncryptionOptions{Passphrase: "secret"}}
+	db, err := NewStore(dbPath, options)
+	assert.Nil(err)
+
+	value := []byte("plain value")
+	db.Put([]byte("k1"), value)
+	batch := db.NewBatch()
+	batch.Put([]byte("k2"), value)
+	assert.Nil(batch.Write())
+
+	opt, _ := db.Get([]byte("k1"))
+	assert.Equal(value, opt.V)
+	snap, _ := db.NewSnapshot()
+	opt, _ = snap.Get([]byte("k2"))
+	snap.Release()
+	assert.Equal(value, opt.V)
+
+	var keys []string
+	iter := db.NewIterator(NewRange(nil, nil))
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()))
+		assert.Equal(value, iter.Value())
+	}
+	assert.Nil(iter.Error())
+	iter.Release()
+	assert.Equal([]string{"k1", "k2"}, keys, "data key hidden")
+	db.Close()
+
+	// values are not stored in plaintext
+	plain, err := NewStore(dbPath, Options{Encryption: nil})
+	assert.NotNil(err, "encrypted store can't be opened without key")
+	assert.Nil(plain)
+	files, _ := ioutil.ReadDir(dbPath)
+	for _, f := range files {
+		data, _ := ioutil.ReadFile(dbPath + "/" + f.Name())
+		assert.False(bytes.Contains(data, value))
+	}
+
+	_, err = NewStore(dbPath, Options{Encryption: &EncryptionOptions{Passphrase: "wrong"}})
+	assert.NotNil(err)
+
+	db, err = NewStore(dbPath, options)
+	assert.Nil(err)
+	defer db.Close()
+	opt, _ = db.Get([]byte("k2"))
+	assert.Equal(value, opt.V)
+
+	// unencrypted store can't be encrypted
+	mem, _ := NewMemStore(Options{})
+	mem.Put([]byte("k"), value)
+	mem.Close()
+	key := bytes.Repeat([]byte{1}, 32)
+	mem, err = NewMemStore(Options{Encryption: &EncryptionOptions{Key: key}})
+	assert.Nil(err, "new mem store is empty")
+	mem.Close()
+}
@@ -9,16 +9,16 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
-var writeOpt = &opt.WriteOptions{
-	Sync: true, // to prevent data loss
-}
-
 // implements Store interface
 type levelDB struct {
-	db *leveldb.DB
+	db        *leveldb.DB
+	s         storage.Storage
+	committer *committer
 }
 
-func newLevelDB(s storage.Storage, cacheSize int, openFilesCacheCapacity int) (*levelDB, error) {
+func newLevelDB(s storage.Storage, options Options, preSync func() error) (*levelDB, error) {
+	cacheSize := options.CacheSize
+	openFilesCacheCapacity := options.OpenFilesCacheCapacity
 	if cacheSize < 128 {
 		cacheSize = 128
 	}
@@ -37,26 +37,45 @@ func newLevelDB(s storage.Storage, cacheSize int, openFilesCacheCapacity int) (*
 	if err != nil {
 		return nil, errors.Wrap(err, "new level db")
 	}
-	return &levelDB{db: db}, nil
+	committer, err := newCommitter(db, options, preSync)
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "new level db")
+	}
+	return &levelDB{db: db, s: s, committer: committer}, nil
 }
 
-func newMemLevelDB(cacheSize int) (*levelDB, error) {
+func newMemLevelDB(options Options) (*levelDB, error) {
 	s := storage.NewMemStorage()
-	return newLevelDB(s, cacheSize, 0)
+	options.OpenFilesCacheCapacity = 0
+	return newLevelDB(s, options, nil)
 }
 
-func newFSLevelDB(filePath string, cacheSize int, openFilesCacheCapacity int) (*levelDB, error) {
+func newFSLevelDB(filePath string, options Options, preSync func() error) (*levelDB, error) {
 	s, err := storage.OpenFile(filePath, false)
 	if err != nil {
 		return nil, errors.Wrap(err, "new fs level db")
 	}
-	return newLevelDB(s, cacheSize, openFilesCacheCapacity)
+	ldb, err := newLevelDB(s, options, preSync)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return ldb, nil
 }
 
 func (ldb *levelDB) Has(key []byte) (bool, error) {
 	return ldb.db.Has(key, nil)
 }
 
+// found returns value found by key, non-nil even if empty
+func found(data []byte) *OptValue {
+	if data == nil {
+		data = []byte{}
+	}
+	return &OptValue{data}
+}
+
 func (ldb *levelDB) Get(key []byte) (*OptValue, error) {
 	data, err := ldb.db.Get(key, nil)
 	if err != nil {
@@ -65,39 +84,56 @@ func (ldb *levelDB) Get(key []byte) (*OptValue, error) {
 		}
 		return nil, errors.Wrap(err, "get")
 	}
-	return &OptValue{data}, nil
+	return found(data), nil
 }
 
 func (ldb *levelDB) Put(key []byte, value []byte) error {
-	return errors.Wrap(ldb.db.Put(key, value, writeOpt), "put")
+	batch := &leveldb.Batch{}
+	batch.Put(key, value)
+	return errors.Wrap(ldb.committer.commit(batch), "put")
 }
 
 func (ldb *levelDB) NewIterator(r *Range) Iterator {
 	return ldb.db.NewIterator(&util.Range{Start: r.from, Limit: r.to}, nil)
 }
 
+func (ldb *levelDB) NewSnapshot() (Snapshot, error) {
+	snap, err := ldb.db.GetSnapshot()
+	if err != nil {
+		return nil, errors.Wrap(err, "new snapshot")
+	}
+	return &levelDBSnapshot{snap}, nil
+}
+
 func (ldb *levelDB) Delete(key []byte) error {
-	if err := ldb.db.Delete(key, writeOpt); err != nil {
+	batch := &leveldb.Batch{}
+	batch.Delete(key)
+	if err := ldb.committer.commit(batch); err != nil {
 		return errors.Wrap(err, "delete")
 	}
 	return nil
 }
 
 func (ldb *levelDB) Close() error {
-	return errors.Wrap(ldb.db.Close(), "close")
+	ldb.committer.close()
+	if err := ldb.db.Close(); err != nil {
+		return errors.Wrap(err, "close")
+	}
+	// release the storage lock, which is not done by db
+	return errors.Wrap(ldb.s.Close(), "close")
 }
 
 func (ldb *levelDB) NewBatch() Batch {
 	return &levelDBBatch{
-		db:    ldb.db,
-		batch: &leveldb.Batch{},
+		committer: ldb.committer,
+		batch:     &leveldb.Batch{},
 	}
 }
 
 // implements Batch interface
 type levelDBBatch struct {
-	db    *leveldb.DB
-	batch *leveldb.Batch
+	committer *committer
+	batch     *leveldb.Batch
 }
 
 func (batch *levelDBBatch) Delete(key []byte) error {
@@ -115,9 +151,37 @@ func (batch *levelDBBatch) Reset() {
 }
 
 func (batch *levelDBBatch) Write() error {
-	return errors.Wrap(batch.db.Write(batch.batch, writeOpt), "write batch")
+	return errors.Wrap(batch.committer.commit(batch.batch), "write batch")
 }
 
 func (batch *levelDBBatch) Len() int {
 	return batch.batch.Len()
 }
+
+// implements Snapshot interface
+type levelDBSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+func (s *levelDBSnapshot) Has(key []byte) (bool, error) {
+	return s.snap.Has(key, nil)
+}
+
+func (s *levelDBSnapshot) Get(key []byte) (*OptValue, error) {
+	data, err := s.snap.Get(key, nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return &OptValue{}, nil
+		}
+		return nil, errors.Wrap(err, "get")
+	}
+	return found(data), nil
+}
+
+func (s *levelDBSnapshot) NewIterator(r *Range) Iterator {
+	return s.snap.NewIterator(&util.Range{Start: r.from, Limit: r.to}, nil)
+}
+
+func (s *levelDBSnapshot) Release() {
+	s.snap.Release()
+}
@@ -0,0 +1,709 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+const (
+	// values smaller than it are stored inline in index by default
+	defaultValueThreshold = 1024
+	// segment is sealed once its size reaches the limit
+	segmentMaxSize = 64 * 1024 * 1024
+	// sealed segment is compacted if ratio of live data not greater than it
+	segmentCompactRatio = 0.5
+	// max count of values moved in one compaction batch
+	compactBatchLen = 256
+
+	segmentFileExt = ".seg"
+
+	// record header composed of key length, value length and value crc
+	recordHeaderLen = 12
+	// pointer composed of segment id, value offset, value length and value crc
+	pointerLen = 20
+)
+
+// tags of index value
+const (
+	tagInline  = byte(0)
+	tagPointer = byte(1)
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// valuePointer locates value in segment
+type valuePointer struct {
+	segmentID uint32
+	offset    int64
+	length    uint32
+	crc       uint32
+}
+
+func (p *valuePointer) encode() []byte {
+	b := make([]byte, 1+pointerLen)
+	b[0] = tagPointer
+	binary.BigEndian.PutUint32(b[1:], p.segmentID)
+	binary.BigEndian.PutUint64(b[5:], uint64(p.offset))
+	binary.BigEndian.PutUint32(b[13:], p.length)
+	binary.BigEndian.PutUint32(b[17:], p.crc)
+	return b
+}
+
+func decodePointer(b []byte) (*valuePointer, error) {
+	if len(b) != 1+pointerLen || b[0] != tagPointer {
+		return nil, errors.New("invalid value pointer")
+	}
+	return &valuePointer{
+		segmentID: binary.BigEndian.Uint32(b[1:]),
+		offset:    int64(binary.BigEndian.Uint64(b[5:])),
+		length:    binary.BigEndian.Uint32(b[13:]),
+		crc:       binary.BigEndian.Uint32(b[17:]),
+	}, nil
+}
+
+// segmentFile an append-only file of records
+type segmentFile struct {
+	id   uint32
+	path string
+	file *os.File
+	// size is only modified when appending, which is serialized by writeMu
+	size int64
+	// obsolete segment remains readable until removed
+	obsolete bool
+	// count of batches appended but not yet indexed, or syncs in progress, protected by segmentStore.mu.
+	// Such segment is never compacted, as its live values are unknown to index.
+	pending int
+}
+
+func segmentFileName(id uint32) string {
+	return fmt.Sprintf("%08d%s", id, segmentFileExt)
+}
+
+// segmentStore implements Store interface. Values not smaller than threshold are appended to segment files,
+// and LevelDB only indexes them. It avoids large values being rewritten by LevelDB compaction.
+type segmentStore struct {
+	index     *levelDB
+	dir       string
+	threshold int
+
+	// serializes writes and compaction
+	writeMu sync.Mutex
+	active  *segmentFile
+
+	// protects fields below
+	mu       sync.Mutex
+	segments map[uint32]*segmentFile
+	// count of readers which may resolve value pointers
+	refs int
+	// segments to be removed once no reader
+	obsolete []*segmentFile
+}
+
+func newSegmentStore(dir string, options Options) (*segmentStore, error) {
+	if _, err := os.Stat(filepath.Join(dir, "CURRENT")); err == nil {
+		return nil, errors.New("new segment store: dir occupied by leveldb engine")
+	}
+	segmentsDir := filepath.Join(dir, "segments")
+	if err := os.MkdirAll(segmentsDir, 0700); err != nil {
+		return nil, errors.Wrap(err, "new segment store")
+	}
+	threshold := options.ValueThreshold
+	if threshold <= 0 {
+		threshold = defaultValueThreshold
+	}
+
+	s := &segmentStore{
+		dir:       segmentsDir,
+		threshold: threshold,
+		segments:  make(map[uint32]*segmentFile),
+	}
+	files, err := ioutil.ReadDir(segmentsDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "new segment store")
+	}
+	var maxID uint32
+	for _, fi := range files {
+		name := fi.Name()
+		if !strings.HasSuffix(name, segmentFileExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentFileExt), 10, 32)
+		if err != nil {
+			continue
+		}
+		seg, err := openSegmentFile(segmentsDir, uint32(id))
+		if err != nil {
+			s.closeSegments()
+			return nil, errors.Wrap(err, "new segment store")
+		}
+		s.segments[seg.id] = seg
+		if seg.id > maxID {
+			maxID = seg.id
+		}
+	}
+	// never append to existing segments, whose tail may be torn
+	if err := s.newActive(maxID + 1); err != nil {
+		s.closeSegments()
+		return nil, errors.Wrap(err, "new segment store")
+	}
+
+	index, err := newFSLevelDB(filepath.Join(dir, "index"), options, s.syncActive)
+	if err != nil {
+		s.closeSegments()
+		return nil, err
+	}
+	s.index = index
+	return s, nil
+}
+
+func openSegmentFile(dir string, id uint32) (*segmentFile, error) {
+	path := filepath.Join(dir, segmentFileName(id))
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &segmentFile{
+		id:   id,
+		path: path,
+		file: file,
+		size: fi.Size(),
+	}, nil
+}
+
+// newActive create a new segment for appending
+func (s *segmentStore) newActive(id uint32) error {
+	seg, err := openSegmentFile(s.dir, id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.segments[id] = seg
+	s.mu.Unlock()
+	s.active = seg
+	return nil
+}
+
+// rotate seal active segment if not empty. writeMu should be held.
+func (s *segmentStore) rotate() error {
+	if s.active.size == 0 {
+		return nil
+	}
+	// sealed segment is no longer synced by syncActive
+	if err := s.active.file.Sync(); err != nil {
+		return err
+	}
+	return s.newActive(s.active.id + 1)
+}
+
+// syncActive sync active segment, so that appended values are durable before indexed
+func (s *segmentStore) syncActive() error {
+	s.writeMu.Lock()
+	seg := s.active
+	// it may be rotated meanwhile, and should not be compacted while syncing
+	s.mu.Lock()
+	seg.pending++
+	s.mu.Unlock()
+	s.writeMu.Unlock()
+	defer s.donePending([]*segmentFile{seg})
+	return seg.file.Sync()
+}
+
+// append append a record to active segment. writeMu should be held.
+func (s *segmentStore) append(key, value []byte) (*valuePointer, error) {
+	if s.active.size >= segmentMaxSize {
+		if err := s.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	crc := crc32.Checksum(value, crcTable)
+	record := make([]byte, recordHeaderLen+len(key)+len(value))
+	binary.BigEndian.PutUint32(record, uint32(len(key)))
+	binary.BigEndian.PutUint32(record[4:], uint32(len(value)))
+	binary.BigEndian.PutUint32(record[8:], crc)
+	copy(record[recordHeaderLen:], key)
+	copy(record[recordHeaderLen+len(key):], value)
+
+	if _, err := s.active.file.Write(record); err != nil {
+		return nil, err
+	}
+	ptr := &valuePointer{
+		segmentID: s.active.id,
+		offset:    s.active.size + recordHeaderLen + int64(len(key)),
+		length:    uint32(len(value)),
+		crc:       crc,
+	}
+	s.active.size += int64(len(record))
+	return ptr, nil
+}
+
+func (s *segmentStore) segment(id uint32) *segmentFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.segments[id]
+}
+
+// acquire prevent obsolete segments being removed
+func (s *segmentStore) acquire() {
+	s.mu.Lock()
+	s.refs++
+	s.mu.Unlock()
+}
+
+func (s *segmentStore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs--
+	if s.refs == 0 {
+		s.removeObsolete()
+	}
+}
+
+// removeObsolete remove obsolete segment files. mu should be held.
+func (s *segmentStore) removeObsolete() {
+	for _, seg := range s.obsolete {
+		delete(s.segments, seg.id)
+		seg.file.Close()
+		if err := os.Remove(seg.path); err != nil {
+			log.Warnf("segment store: remove segment: %v", err)
+		}
+	}
+	s.obsolete = nil
+}
+
+// resolve get value by index value
+func (s *segmentStore) resolve(indexValue []byte) ([]byte, error) {
+	if len(indexValue) == 0 {
+		return nil, errors.New("invalid index value")
+	}
+	if indexValue[0] == tagInline {
+		return indexValue[1:], nil
+	}
+	ptr, err := decodePointer(indexValue)
+	if err != nil {
+		return nil, err
+	}
+	seg := s.segment(ptr.segmentID)
+	if seg == nil {
+		return nil, errors.Wrapf(ErrCorrupt, "segment %d not found", ptr.segmentID)
+	}
+	value := make([]byte, ptr.length)
+	if _, err := seg.file.ReadAt(value, ptr.offset); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errors.Wrapf(ErrCorrupt, "truncated segment %d at %d", ptr.segmentID, ptr.offset)
+		}
+		return nil, err
+	}
+	if crc32.Checksum(value, crcTable) != ptr.crc {
+		return nil, errors.Wrapf(ErrCorrupt, "segment %d at %d", ptr.segmentID, ptr.offset)
+	}
+	return value, nil
+}
+
+func (s *segmentStore) Has(key []byte) (bool, error) {
+	return s.index.Has(key)
+}
+
+func (s *segmentStore) Get(key []byte) (*OptValue, error) {
+	s.acquire()
+	defer s.release()
+
+	indexValue, err := s.index.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if indexValue.V == nil {
+		return indexValue, nil
+	}
+	value, err := s.resolve(indexValue.V)
+	if err != nil {
+		return nil, errors.Wrap(err, "get")
+	}
+	return &OptValue{value}, nil
+}
+
+func (s *segmentStore) Put(key []byte, value []byte) error {
+	batch := s.NewBatch()
+	batch.Put(key, value)
+	return errors.Wrap(batch.Write(), "put")
+}
+
+func (s *segmentStore) Delete(key []byte) error {
+	// space of the value is reclaimed by compaction
+	return s.index.Delete(key)
+}
+
+func (s *segmentStore) NewIterator(r *Range) Iterator {
+	s.acquire()
+	return &segmentIterator{
+		Iterator: s.index.NewIterator(r),
+		store:    s,
+	}
+}
+
+func (s *segmentStore) NewSnapshot() (Snapshot, error) {
+	s.acquire()
+	snap, err := s.index.NewSnapshot()
+	if err != nil {
+		s.release()
+		return nil, err
+	}
+	return &segmentSnapshot{
+		Snapshot: snap,
+		store:    s,
+	}, nil
+}
+
+func (s *segmentStore) NewBatch() Batch {
+	return &segmentBatch{store: s}
+}
+
+func (s *segmentStore) closeSegments() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, seg := range s.segments {
+		seg.file.Close()
+	}
+	s.removeObsolete()
+}
+
+func (s *segmentStore) Close() error {
+	// pending commits may sync active segment, so close index first
+	err := s.index.Close()
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.closeSegments()
+	return err
+}
+
+// Compact move live values out of sealed segments which are mostly garbage, and remove those segments.
+func (s *segmentStore) Compact(ctx context.Context) error {
+	// seal active segment, so that all segments are candidates
+	s.writeMu.Lock()
+	err := s.rotate()
+	activeID := s.active.id
+	s.writeMu.Unlock()
+	if err != nil {
+		return errors.Wrap(err, "compact")
+	}
+
+	live, err := s.liveBytes()
+	if err != nil {
+		return errors.Wrap(err, "compact")
+	}
+	targets := make(map[uint32]*segmentFile)
+	s.mu.Lock()
+	for id, seg := range s.segments {
+		if id < activeID && !seg.obsolete && seg.pending == 0 && float64(live[id]) <= float64(seg.size)*segmentCompactRatio {
+			targets[id] = seg
+		}
+	}
+	s.mu.Unlock()
+	if len(targets) == 0 {
+		return nil
+	}
+
+	moved, err := s.moveLiveValues(ctx, targets)
+	if err != nil {
+		return errors.Wrap(err, "compact")
+	}
+
+	s.mu.Lock()
+	var ids []int
+	for id, seg := range targets {
+		seg.obsolete = true
+		s.obsolete = append(s.obsolete, seg)
+		ids = append(ids, int(id))
+	}
+	if s.refs == 0 {
+		s.removeObsolete()
+	}
+	s.mu.Unlock()
+	sort.Ints(ids)
+	log.Infof("segment store: compacted segments %v, %d values moved", ids, moved)
+	return nil
+}
+
+// liveBytes returns size of records referenced by index for each segment
+func (s *segmentStore) liveBytes() (map[uint32]int64, error) {
+	live := make(map[uint32]int64)
+	iter := s.index.db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if iter.Value()[0] != tagPointer {
+			continue
+		}
+		ptr, err := decodePointer(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		live[ptr.segmentID] += recordHeaderLen + int64(len(iter.Key())) + int64(ptr.length)
+	}
+	return live, iter.Error()
+}
+
+// moveLiveValues append values referenced by index in target segments to active segment
+func (s *segmentStore) moveLiveValues(ctx context.Context, targets map[uint32]*segmentFile) (int, error) {
+	type entry struct {
+		key        []byte
+		indexValue []byte
+	}
+	var (
+		pending []entry
+		moved   int
+	)
+	flush := func() error {
+		s.writeMu.Lock()
+		defer s.writeMu.Unlock()
+		// prevent index changed between check and write
+		s.index.committer.mu.Lock()
+		defer s.index.committer.mu.Unlock()
+
+		batch := &leveldb.Batch{}
+		for _, e := range pending {
+			current, err := s.index.Get(e.key)
+			if err != nil {
+				return err
+			}
+			// skip values changed since scanned
+			if !bytes.Equal(current.V, e.indexValue) {
+				continue
+			}
+			value, err := s.resolve(e.indexValue)
+			if err != nil {
+				return err
+			}
+			ptr, err := s.append(e.key, value)
+			if err != nil {
+				return err
+			}
+			batch.Put(e.key, ptr.encode())
+		}
+		pending = pending[:0]
+		if batch.Len() == 0 {
+			return nil
+		}
+		if err := s.active.file.Sync(); err != nil {
+			return err
+		}
+		moved += batch.Len()
+		return s.index.db.Write(batch, syncWriteOpt)
+	}
+
+	iter := s.index.db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if iter.Value()[0] != tagPointer {
+			continue
+		}
+		ptr, err := decodePointer(iter.Value())
+		if err != nil {
+			return 0, err
+		}
+		if targets[ptr.segmentID] == nil {
+			continue
+		}
+		pending = append(pending, entry{
+			key:        append([]byte(nil), iter.Key()...),
+			indexValue: append([]byte(nil), iter.Value()...),
+		})
+		if len(pending) >= compactBatchLen {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			default:
+			}
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// implements Batch interface
+type segmentBatch struct {
+	store *segmentStore
+	ops   []batchOp
+}
+
+type batchOp struct {
+	key   []byte
+	value []byte
+	del   bool
+}
+
+func (batch *segmentBatch) Put(key []byte, value []byte) error {
+	batch.ops = append(batch.ops, batchOp{
+		key:   append([]byte(nil), key...),
+		value: append([]byte(nil), value...),
+	})
+	return nil
+}
+
+func (batch *segmentBatch) Delete(key []byte) error {
+	batch.ops = append(batch.ops, batchOp{
+		key: append([]byte(nil), key...),
+		del: true,
+	})
+	return nil
+}
+
+func (batch *segmentBatch) Reset() {
+	batch.ops = nil
+}
+
+func (batch *segmentBatch) Len() int {
+	return len(batch.ops)
+}
+
+func (batch *segmentBatch) Write() error {
+	ldbBatch, appended, err := batch.appendValues()
+	defer batch.store.donePending(appended)
+	if err != nil {
+		return errors.Wrap(err, "write batch")
+	}
+	// active segment is synced by committer before indexed if required
+	return errors.Wrap(batch.store.index.committer.commit(ldbBatch), "write batch")
+}
+
+// donePending mark appends to segments indexed or abandoned
+func (s *segmentStore) donePending(segs []*segmentFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, seg := range segs {
+		seg.pending--
+	}
+}
+
+// appendValues append large values to segment, and returns batch for index,
+// along with segments appended, which are pending until donePending called.
+func (batch *segmentBatch) appendValues() (*leveldb.Batch, []*segmentFile, error) {
+	s := batch.store
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var appended []*segmentFile
+	ldbBatch := &leveldb.Batch{}
+	for _, op := range batch.ops {
+		if op.del {
+			ldbBatch.Delete(op.key)
+			continue
+		}
+		if len(op.value) < s.threshold {
+			ldbBatch.Put(op.key, append([]byte{tagInline}, op.value...))
+			continue
+		}
+		ptr, err := s.append(op.key, op.value)
+		if err != nil {
+			return nil, appended, err
+		}
+		// active segment only changes by rotation, so a segment is recorded once
+		if n := len(appended); n == 0 || appended[n-1] != s.active {
+			s.mu.Lock()
+			s.active.pending++
+			s.mu.Unlock()
+			appended = append(appended, s.active)
+		}
+		ldbBatch.Put(op.key, ptr.encode())
+	}
+	return ldbBatch, appended, nil
+}
+
+// implements Iterator interface, with values resolved from segments
+type segmentIterator struct {
+	Iterator
+	store    *segmentStore
+	err      error
+	released bool
+}
+
+func (iter *segmentIterator) Value() []byte {
+	value, err := iter.store.resolve(iter.Iterator.Value())
+	if err != nil {
+		iter.err = errors.Wrap(err, "iterator")
+		return nil
+	}
+	return value
+}
+
+func (iter *segmentIterator) Error() error {
+	if iter.err != nil {
+		return iter.err
+	}
+	return iter.Iterator.Error()
+}
+
+func (iter *segmentIterator) Release() {
+	if iter.released {
+		return
+	}
+	iter.released = true
+	iter.Iterator.Release()
+	iter.store.release()
+}
+
+// implements Snapshot interface, with values resolved from segments.
+// It holds a reference to prevent segments being removed.
+type segmentSnapshot struct {
+	Snapshot
+	store    *segmentStore
+	released bool
+}
+
+func (snap *segmentSnapshot) Get(key []byte) (*OptValue, error) {
+	indexValue, err := snap.Snapshot.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if indexValue.V == nil {
+		return indexValue, nil
+	}
+	value, err := snap.store.resolve(indexValue.V)
+	if err != nil {
+		return nil, errors.Wrap(err, "get")
+	}
+	return &OptValue{value}, nil
+}
+
+func (snap *segmentSnapshot) NewIterator(r *Range) Iterator {
+	snap.store.acquire()
+	return &segmentIterator{
+		Iterator: snap.Snapshot.NewIterator(r),
+		store:    snap.store,
+	}
+}
+
+func (snap *segmentSnapshot) Release() {
+	if snap.released {
+		return
+	}
+	snap.released = true
+	snap.Snapshot.Release()
+	snap.store.release()
+}
@@ -0,0 +1,148 @@
+package kv_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/vechain/solidb/kv"
+)
+
+func segmentFiles(dir string) []string {
+	files, _ := filepath.Glob(filepath.Join(dir, "segments", "*.seg"))
+	return files
+}
+
+func TestSegmentStore(t *testing.T) {
+	assert := assert.New(t)
+	dbPath, _ := ioutil.TempDir(os.TempDir(), "db")
+	defer os.RemoveAll(dbPath)
+
+	options := Options{Engine: EngineSegment, ValueThreshold: 16}
+	db, err := NewStore(dbPath, options)
+	assert.Nil(err)
+
+	small := []byte("v")
+	large := bytes.Repeat([]byte("large"), 100)
+	db.Put([]byte("k1"), small)
+	db.Put([]byte("k2"), large)
+
+	batch := db.NewBatch()
+	for i := 0; i < 10; i++ {
+		batch.Put([]byte(fmt.Sprintf("b%d", i)), large)
+	}
+	batch.Delete([]byte("k1"))
+	assert.Nil(batch.Write())
+
+	opt, _ := db.Get([]byte("k1"))
+	assert.Nil(opt.V)
+	opt, _ = db.Get([]byte("k2"))
+	assert.Equal(large, opt.V)
+
+	iter := db.NewIterator(NewRangeWithBytesPrefix([]byte("b")))
+	n := 0
+	for iter.Next() {
+		assert.Equal(large, iter.Value())
+		n++
+	}
+	assert.Nil(iter.Error())
+	iter.Release()
+	assert.Equal(10, n)
+
+	// reopen
+	db.Close()
+	_, err = NewStore(dbPath, Options{})
+	assert.NotNil(err, "engine can't be changed")
+
+	db, err = NewStore(dbPath, options)
+	assert.Nil(err)
+	defer db.Close()
+	opt, _ = db.Get([]byte("b9"))
+	assert.Equal(large, opt.V)
+
+	// make the first segment mostly garbage
+	for i := 0; i < 9; i++ {
+		db.Delete([]byte(fmt.Sprintf("b%d", i)))
+	}
+	db.Put([]byte("k3"), small)
+	before := segmentFiles(dbPath)
+
+	// open iterator and snapshot defer removal of compacted segments
+	iter = db.NewIterator(NewRangeWithBytesPrefix([]byte("b")))
+	snap, _ := db.NewSnapshot()
+	assert.Nil(db.(Compacter).Compact(context.Background()))
+	assert.Equal(before, segmentFiles(dbPath))
+	assert.True(iter.Next())
+	assert.Equal(large, iter.Value())
+	iter.Release()
+	db.Put([]byte("b9"), small)
+	opt, _ = snap.Get([]byte("b9"))
+	assert.Equal(large, opt.V)
+	assert.Equal(before, segmentFiles(dbPath))
+	snap.Release()
+	assert.Equal(1, len(segmentFiles(dbPath)))
+
+	opt, _ = db.Get([]byte("k2"))
+	assert.Equal(large, opt.V)
+	for _, k := range []string{"k3", "b9"} {
+		opt, _ = db.Get([]byte(k))
+		assert.Equal(small, opt.V)
+	}
+}
+
+func TestSegmentConcurrentCompact(t *testing.T) {
+	assert := assert.New(t)
+	dbPath, _ := ioutil.TempDir(os.TempDir(), "db")
+	defer os.RemoveAll(dbPath)
+
+	db, err := NewStore(dbPath, Options{Engine: EngineSegment, ValueThreshold: 16})
+	assert.Nil(err)
+	defer db.Close()
+
+	value := func(w, i int) []byte {
+		return bytes.Repeat([]byte(fmt.Sprintf("%d-%d|", w, i)), 20)
+	}
+	const writers, rounds = 8, 200
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				// overwrite to make garbage
+				key := []byte(fmt.Sprintf("k%d", w))
+				assert.Nil(db.Put(key, value(w, i)))
+				opt, err := db.Get(key)
+				if assert.Nil(err) {
+					assert.Equal(value(w, i), opt.V)
+				}
+			}
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for compacting := true; compacting; {
+		select {
+		case <-done:
+			compacting = false
+		default:
+		}
+		assert.Nil(db.(Compacter).Compact(context.Background()))
+	}
+
+	for w := 0; w < writers; w++ {
+		opt, err := db.Get([]byte(fmt.Sprintf("k%d", w)))
+		if assert.Nil(err) {
+			assert.Equal(value(w, rounds-1), opt.V)
+		}
+	}
+}
@@ -1,6 +1,23 @@
 // Package kv provides key-value based storage.
 package kv
 
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCorrupt returned when a stored value fails to be read back intact, e.g. checksum mismatch or decryption failure.
+var ErrCorrupt = errors.New("corrupt value")
+
+// IsCorrupt returns whether err is caused by a corrupt value.
+func IsCorrupt(err error) bool {
+	return errors.Cause(err) == ErrCorrupt
+}
+
 type OptValue struct {
 	V []byte
 }
@@ -23,16 +40,32 @@ type Reader interface {
 	Get(key []byte) (*OptValue, error)
 }
 
+// View readable view of kv pairs
+type View interface {
+	Reader
+
+	// NewIterator create iterator to iterates kv pairs for the given range.
+	NewIterator(r *Range) Iterator
+}
+
+// Snapshot point-in-time view of store, which is unaffected by later writes.
+type Snapshot interface {
+	View
+
+	// Release release the snapshot. It should be called after use.
+	Release()
+}
+
 // Store interface of key-value storage.
 type Store interface {
-	Reader
+	View
 	Writer
 
 	// NewBatch create batch object for batch writes.
 	NewBatch() Batch
 
-	// NewIterator create iterator to iterates kv pairs for the given range.
-	NewIterator(r *Range) Iterator
+	// NewSnapshot create a snapshot of current state.
+	NewSnapshot() (Snapshot, error)
 
 	// Close close the store.
 	Close() error
@@ -69,21 +102,76 @@ type Iterator interface {
 	Value() []byte
 }
 
+// Compacter is implemented by stores which reclaim space of deleted values on demand.
+type Compacter interface {
+	// Compact reclaim space occupied by deleted or overwritten values.
+	Compact(ctx context.Context) error
+}
+
+// storage engines
+const (
+	// EngineLevelDB stores all values in LevelDB
+	EngineLevelDB = "leveldb"
+	// EngineSegment appends large values to segment files, and indexes them in LevelDB
+	EngineSegment = "segment"
+)
+
 // Options options to initialize store
 type Options struct {
 	CacheSize              int
 	OpenFilesCacheCapacity int
+	// Engine storage engine, EngineLevelDB if empty
+	Engine string
+	// ValueThreshold values not smaller than it are stored in segment files, only for EngineSegment
+	ValueThreshold int
+	// Durability durability mode of writes, DurabilitySync if empty
+	Durability string
+	// MaxCommitDelay max delay of writes to be grouped, only for DurabilityGroup
+	MaxCommitDelay time.Duration
+	// Encryption encrypts values at rest if not nil. It can't be changed once the store has data.
+	Encryption *EncryptionOptions
 }
 
 // NewStore create/open kv store at specified file path.
+// The engine can't be changed once the store created.
 func NewStore(filePath string, options Options) (Store, error) {
-	return newFSLevelDB(
-		filePath,
-		options.CacheSize,
-		options.OpenFilesCacheCapacity)
+	var store Store
+	switch options.Engine {
+	case "", EngineLevelDB:
+		if _, err := os.Stat(filepath.Join(filePath, "segments")); err == nil {
+			return nil, errors.New("new store: dir occupied by segment engine")
+		}
+		ldb, err := newFSLevelDB(filePath, options, nil)
+		if err != nil {
+			return nil, err
+		}
+		store = ldb
+	case EngineSegment:
+		s, err := newSegmentStore(filePath, options)
+		if err != nil {
+			return nil, err
+		}
+		store = s
+	default:
+		return nil, errors.Errorf("new store: unknown engine %s", options.Engine)
+	}
+	return withEncryption(store, options)
 }
 
 // NewMemStore create kv store in memory, for test purpose
 func NewMemStore(options Options) (Store, error) {
-	return newMemLevelDB(options.CacheSize)
+	ldb, err := newMemLevelDB(options)
+	if err != nil {
+		return nil, err
+	}
+	return withEncryption(ldb, options)
+}
+
+func withEncryption(store Store, options Options) (Store, error) {
+	es, err := openEncryption(store, options.Encryption)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	return es, nil
 }
@@ -1,9 +1,11 @@
 package kv_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,6 +30,9 @@ func TestBasic(t *testing.T) {
 		{op: "get", key: "k1", value: []byte("v")},
 		{op: "del", key: "k1"},
 		{op: "get", key: "k1"},
+		// empty value is distinct from absent
+		{op: "put", key: "k2", value: []byte{}},
+		{op: "get", key: "k2", value: []byte{}},
 	}
 
 	for _, c := range cases {
@@ -109,3 +114,67 @@ func BenchmarkStore(b *testing.B) {
 		}
 	})
 }
+
+func TestSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	db, _ := NewMemStore(Options{})
+	defer db.Close()
+
+	db.Put([]byte("k1"), []byte("v1"))
+	snap, err := db.NewSnapshot()
+	assert.Nil(err)
+	defer snap.Release()
+
+	db.Put([]byte("k1"), []byte("v2"))
+	db.Put([]byte("k2"), []byte("v2"))
+
+	opt, _ := snap.Get([]byte("k1"))
+	assert.Equal([]byte("v1"), opt.V)
+	has, _ := snap.Has([]byte("k2"))
+	assert.False(has)
+
+	iter := snap.NewIterator(NewRangeWithBytesPrefix([]byte("k")))
+	defer iter.Release()
+	var keys []string
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	assert.Equal([]string{"k1"}, keys)
+}
+
+func TestDurability(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, engine := range []string{EngineLevelDB, EngineSegment} {
+		for _, durability := range []string{DurabilitySync, DurabilityGroup, DurabilityAsync} {
+			dbPath, _ := ioutil.TempDir(os.TempDir(), "db")
+			defer os.RemoveAll(dbPath)
+
+			options := Options{Engine: engine, Durability: durability, ValueThreshold: 4}
+			db, err := NewStore(dbPath, options)
+			assert.Nil(err)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					assert.Nil(db.Put([]byte(fmt.Sprintf("k%d", i)), []byte(fmt.Sprintf("value%d", i))))
+				}(i)
+			}
+			wg.Wait()
+			assert.Nil(db.Close())
+
+			db, _ = NewStore(dbPath, options)
+			for i := 0; i < 50; i++ {
+				opt, _ := db.Get([]byte(fmt.Sprintf("k%d", i)))
+				assert.Equal([]byte(fmt.Sprintf("value%d", i)), opt.V, engine+"/"+durability)
+			}
+			db.Close()
+		}
+	}
+
+	_, err := NewMemStore(Options{Durability: "unknown"})
+	assert.NotNil(err)
+}
@@ -0,0 +1,192 @@
+package kv
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// Table namespace of keys sharing a prefix.
+// Tables are registered globally, so that prefixes of different tables never overlap.
+type Table struct {
+	name   string
+	prefix []byte
+}
+
+var registry struct {
+	sync.Mutex
+	tables []*Table
+}
+
+// NewTable register a table with name and key prefix.
+// It panics if the prefix overlaps with any registered table, so that conflicts are caught at startup.
+func NewTable(name string, prefix string) *Table {
+	if prefix == "" {
+		panic(fmt.Sprintf("kv: empty prefix of table %s", name))
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	for _, t := range registry.tables {
+		if bytes.HasPrefix(t.prefix, []byte(prefix)) || bytes.HasPrefix([]byte(prefix), t.prefix) {
+			panic(fmt.Sprintf("kv: prefix %q of table %s overlaps with table %s", prefix, name, t.name))
+		}
+	}
+	t := &Table{name: name, prefix: []byte(prefix)}
+	registry.tables = append(registry.tables, t)
+	return t
+}
+
+// Name returns name of table
+func (t *Table) Name() string {
+	return t.name
+}
+
+// Key returns key in store of the key in table
+func (t *Table) Key(key []byte) []byte {
+	storeKey := make([]byte, 0, len(t.prefix)+len(key))
+	return append(append(storeKey, t.prefix...), key...)
+}
+
+// TrimKey returns key in table of the key in store, false if the key doesn't belong to the table
+func (t *Table) TrimKey(storeKey []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(storeKey, t.prefix) {
+		return nil, false
+	}
+	return storeKey[len(t.prefix):], true
+}
+
+func (t *Table) storeRange(r *Range) *Range {
+	whole := NewRangeWithBytesPrefix(t.prefix)
+	if r == nil {
+		return whole
+	}
+	to := whole.to
+	if r.to != nil {
+		to = t.Key(r.to)
+	}
+	return &Range{from: t.Key(r.from), to: to}
+}
+
+// Reader returns reader of the table on r
+func (t *Table) Reader(r Reader) Reader {
+	return &tableReader{t, r}
+}
+
+// Writer returns writer of the table on w.
+// Writes of different tables through the same batch are still atomic.
+func (t *Table) Writer(w Writer) Writer {
+	return &tableWriter{t, w}
+}
+
+// View returns view of the table on v
+func (t *Table) View(v View) View {
+	return &tableView{tableReader{t, v}, v}
+}
+
+// Store returns store of the table on s.
+// Closing the returned store doesn't close s.
+func (t *Table) Store(s Store) Store {
+	return &tableStore{tableView{tableReader{t, s}, s}, tableWriter{t, s}, s}
+}
+
+type tableReader struct {
+	t *Table
+	r Reader
+}
+
+func (r *tableReader) Has(key []byte) (bool, error) {
+	return r.r.Has(r.t.Key(key))
+}
+
+func (r *tableReader) Get(key []byte) (*OptValue, error) {
+	return r.r.Get(r.t.Key(key))
+}
+
+type tableWriter struct {
+	t *Table
+	w Writer
+}
+
+func (w *tableWriter) Put(key []byte, value []byte) error {
+	return w.w.Put(w.t.Key(key), value)
+}
+
+func (w *tableWriter) Delete(key []byte) error {
+	return w.w.Delete(w.t.Key(key))
+}
+
+type tableView struct {
+	tableReader
+	v View
+}
+
+func (v *tableView) NewIterator(r *Range) Iterator {
+	return &tableIterator{v.t, v.v.NewIterator(v.t.storeRange(r))}
+}
+
+type tableStore struct {
+	tableView
+	tableWriter
+	s Store
+}
+
+func (s *tableStore) NewBatch() Batch {
+	batch := s.s.NewBatch()
+	return &tableBatch{tableWriter{s.t, batch}, batch}
+}
+
+func (s *tableStore) NewSnapshot() (Snapshot, error) {
+	snap, err := s.s.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &tableSnapshot{tableView{tableReader{s.t, snap}, snap}, snap}, nil
+}
+
+func (s *tableStore) Close() error {
+	return nil
+}
+
+type tableBatch struct {
+	tableWriter
+	batch Batch
+}
+
+func (b *tableBatch) Reset() {
+	b.batch.Reset()
+}
+
+func (b *tableBatch) Write() error {
+	return b.batch.Write()
+}
+
+func (b *tableBatch) Len() int {
+	return b.batch.Len()
+}
+
+type tableSnapshot struct {
+	tableView
+	snap Snapshot
+}
+
+func (s *tableSnapshot) Release() {
+	s.snap.Release()
+}
+
+// tableIterator iterates keys in table, with prefix stripped
+type tableIterator struct {
+	t *Table
+	Iterator
+}
+
+func (i *tableIterator) Seek(key []byte) bool {
+	return i.Iterator.Seek(i.t.Key(key))
+}
+
+func (i *tableIterator) Key() []byte {
+	key := i.Iterator.Key()
+	if key == nil {
+		return nil
+	}
+	return key[len(i.t.prefix):]
+}
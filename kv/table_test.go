@@ -0,0 +1,62 @@
+package kv_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/vechain/solidb/kv"
+)
+
+func TestTable(t *testing.T) {
+	assert := assert.New(t)
+
+	db, _ := NewMemStore(Options{})
+	defer db.Close()
+
+	t1 := NewTable("test-t1", ".test-t1/").Store(db)
+	t2 := NewTable("test-t2", ".test-t2/").Store(db)
+	assert.Panics(func() { NewTable("test-t3", ".test-t1/sub") })
+	assert.Panics(func() { NewTable("test-t4", ".test-t") })
+
+	t1.Put([]byte("k1"), []byte("v1"))
+	t1.Put([]byte("k2"), []byte("v2"))
+	t2.Put([]byte("k1"), []byte("v3"))
+
+	opt, _ := t1.Get([]byte("k1"))
+	assert.Equal([]byte("v1"), opt.V)
+	opt, _ = db.Get([]byte(".test-t2/k1"))
+	assert.Equal([]byte("v3"), opt.V)
+
+	var keys []string
+	iter := t1.NewIterator(nil)
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	iter.Release()
+	assert.Equal([]string{"k1", "k2"}, keys)
+
+	iter = t1.NewIterator(NewRangeWithBytesPrefix([]byte("k2")))
+	assert.True(iter.Next())
+	assert.Equal([]byte("k2"), iter.Key())
+	assert.False(iter.Next())
+	iter.Release()
+
+	snap, _ := t1.NewSnapshot()
+	defer snap.Release()
+
+	// writes to different tables in one batch
+	batch := db.NewBatch()
+	NewTable("test-t5", ".test-t5/").Writer(batch).Put([]byte("k"), []byte("v"))
+	t1Batch := t1.NewBatch()
+	t1Batch.Delete([]byte("k1"))
+	assert.Equal(1, t1Batch.Len())
+	assert.Nil(t1Batch.Write())
+	assert.Nil(batch.Write())
+
+	has, _ := t1.Has([]byte("k1"))
+	assert.False(has)
+	has, _ = snap.Has([]byte("k1"))
+	assert.True(has)
+	has, _ = db.Has([]byte(".test-t5/k"))
+	assert.True(has)
+}
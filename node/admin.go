@@ -0,0 +1,256 @@
+package node
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/crypto"
+	"github.com/vechain/solidb/kv"
+)
+
+const approvalsHeaderKey = "x-solidb-approvals"
+
+// actions approved by admins
+const (
+	ActionProposeSpec = "propose"
+	ActionApproveSpec = "approve"
+	ActionSetAdmins   = "admins"
+	ActionGC          = "gc"
+)
+
+var adminsKey = kv.NewTable("admins", ".admins").Key(nil)
+
+// AdminSet admins of cluster, Threshold of which should approve spec changes and GC sweeps.
+type AdminSet struct {
+	Admins    []string `json:"admins"`
+	Threshold int      `json:"threshold"`
+}
+
+// Validate checks threshold and admin IDs
+func (a *AdminSet) Validate() error {
+	seen := make(map[string]bool)
+	for _, id := range a.Admins {
+		if len(id) != 40 {
+			return errors.Errorf("invalid admin ID %s", id)
+		}
+		if seen[id] {
+			return errors.Errorf("duplicated admin ID %s", id)
+		}
+		seen[id] = true
+	}
+	if a.Threshold < 1 || a.Threshold > len(a.Admins) {
+		return errors.New("threshold should be in [1, count of admins]")
+	}
+	return nil
+}
+
+// Hash returns hash of admin set marshaled into JSON
+func (a *AdminSet) Hash() crypto.Hash {
+	data, _ := json.Marshal(a)
+	return crypto.HashSum(data)
+}
+
+// ApprovalSubject what admins approve.
+// It binds to content rather than request, so that it can be signed offline once, and sent to all nodes.
+type ApprovalSubject struct {
+	ClusterID string `json:"clusterID"`
+	Action    string `json:"action"`
+	// Revision revision of spec, for spec actions, or of approved spec for GC
+	Revision int `json:"revision"`
+	// ContentHash hex of hash of the spec, the new admin set, or the GC sweep request
+	ContentHash string `json:"contentHash"`
+	// BaseHash hex of hash of admin set in effect, for admins action, so that stale changes can't be replayed
+	BaseHash string `json:"baseHash,omitempty"`
+	// Expiry unix time after which approvals are rejected, 0 for never
+	Expiry int64 `json:"expiry,omitempty"`
+}
+
+// SigningHash hash of subject signed by admins
+func (s *ApprovalSubject) SigningHash() crypto.Hash {
+	return crypto.HashSum([]byte(fmt.Sprintf("solidb approval\n%s\n%s\n%d\n%s\n%s\n%d",
+		s.ClusterID, s.Action, s.Revision, s.ContentHash, s.BaseHash, s.Expiry)))
+}
+
+// Approvals subject along with signatures of admins
+type Approvals struct {
+	Subject ApprovalSubject `json:"subject"`
+	// Signatures hex of signatures
+	Signatures []string `json:"signatures"`
+}
+
+// Sign add signature of identity, replacing the previous one of the same identity
+func (a *Approvals) Sign(identity *crypto.Identity) error {
+	sig, err := identity.Sign(a.Subject.SigningHash())
+	if err != nil {
+		return err
+	}
+	signers, _ := a.Signers()
+	var sigs []string
+	for i, s := range a.Signatures {
+		if i >= len(signers) || signers[i] != identity.ID() {
+			sigs = append(sigs, s)
+		}
+	}
+	a.Signatures = append(sigs, hex.EncodeToString(sig))
+	return nil
+}
+
+// Signers returns IDs recovered from signatures, in the same order
+func (a *Approvals) Signers() ([]string, error) {
+	hash := a.Subject.SigningHash()
+	var ids []string
+	for _, s := range a.Signatures {
+		sig, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		id, err := crypto.RecoverID(hash, sig)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Count returns count of distinct admins in set who signed
+func (a *Approvals) Count(set *AdminSet) (int, error) {
+	signers, err := a.Signers()
+	if err != nil {
+		return 0, err
+	}
+	admins := make(map[string]bool)
+	for _, id := range set.Admins {
+		admins[id] = true
+	}
+	count := 0
+	for _, id := range signers {
+		if admins[id] {
+			count++
+			delete(admins, id)
+		}
+	}
+	return count, nil
+}
+
+// Admins returns admin set of cluster, nil if not configured.
+func (n *Node) Admins() (*AdminSet, error) {
+	data, err := n.store.Get(adminsKey)
+	if err != nil {
+		return nil, err
+	}
+	if data.V == nil {
+		return nil, nil
+	}
+	var set AdminSet
+	if err := json.Unmarshal(data.V, &set); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// SetAdmins replace admin set, which should be approved by admins in effect if any.
+func (n *Node) SetAdmins(set *AdminSet, approvals *Approvals) error {
+	n.adminsMu.Lock()
+	defer n.adminsMu.Unlock()
+
+	if err := set.Validate(); err != nil {
+		return err
+	}
+	current, err := n.Admins()
+	if err != nil {
+		return err
+	}
+	// already replaced, to resume partially submitted changes
+	if current != nil && current.Hash() == set.Hash() {
+		return nil
+	}
+	subject := ApprovalSubject{
+		ClusterID:   n.ClusterID(),
+		Action:      ActionSetAdmins,
+		ContentHash: set.Hash().ToHex(),
+	}
+	if current != nil {
+		subject.BaseHash = current.Hash().ToHex()
+	}
+	if err := verifyApprovals(current, &subject, approvals); err != nil {
+		return err
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		return err
+	}
+	return n.store.Put(adminsKey, data)
+}
+
+// checkApproved verify approvals of subject against admin set in effect.
+// Requests are always approved if admins not configured.
+func (n *Node) checkApproved(subject *ApprovalSubject, approvals *Approvals) error {
+	n.adminsMu.RLock()
+	defer n.adminsMu.RUnlock()
+	set, err := n.Admins()
+	if err != nil {
+		return err
+	}
+	subject.ClusterID = n.ClusterID()
+	return verifyApprovals(set, subject, approvals)
+}
+
+func verifyApprovals(set *AdminSet, subject *ApprovalSubject, approvals *Approvals) error {
+	if set == nil {
+		return nil
+	}
+	if approvals == nil {
+		return errors.New("approvals required")
+	}
+	expected := *subject
+	expected.Expiry = approvals.Subject.Expiry
+	if approvals.Subject != expected {
+		return errors.New("approvals not for the request")
+	}
+	if subject.Action == ActionGC && approvals.Subject.Expiry == 0 {
+		return errors.New("approvals of gc should expire")
+	}
+	if expiry := approvals.Subject.Expiry; expiry != 0 && time.Now().Unix() > expiry {
+		return errors.New("approvals expired")
+	}
+	count, err := approvals.Count(set)
+	if err != nil {
+		return err
+	}
+	if count < set.Threshold {
+		return errors.Errorf("approved by %d admins, %d required", count, set.Threshold)
+	}
+	return nil
+}
+
+// encodeApprovals encode approvals into header value
+func encodeApprovals(a *Approvals) (string, error) {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// approvalsFromHeader returns approvals carried by request, nil if absent
+func approvalsFromHeader(header http.Header) (*Approvals, error) {
+	str := header.Get(approvalsHeaderKey)
+	if str == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode approvals")
+	}
+	var a Approvals
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, errors.Wrap(err, "decode approvals")
+	}
+	return &a, nil
+}
@@ -0,0 +1,156 @@
+package node_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/crypto"
+	. "github.com/vechain/solidb/node"
+)
+
+func approve(subject ApprovalSubject, admins ...*crypto.Identity) *Approvals {
+	a := &Approvals{Subject: subject}
+	for _, admin := range admins {
+		if err := a.Sign(admin); err != nil {
+			panic(err)
+		}
+	}
+	return a
+}
+
+func adminSubject(n *Node, base, set *AdminSet) ApprovalSubject {
+	subject := ApprovalSubject{
+		ClusterID:   n.ClusterID(),
+		Action:      ActionSetAdmins,
+		ContentHash: set.Hash().ToHex(),
+	}
+	if base != nil {
+		subject.BaseHash = base.Hash().ToHex()
+	}
+	return subject
+}
+
+func TestSetAdmins(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServer(Options{})
+	defer s.Close()
+	n := s.node
+
+	var keys []*crypto.Identity
+	var ids []string
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateIdentity()
+		keys = append(keys, key)
+		ids = append(ids, key.ID())
+	}
+	set1 := &AdminSet{Admins: ids[:3], Threshold: 2}
+	assert.NotNil(n.SetAdmins(&AdminSet{Admins: []string{ids[0], ids[0]}, Threshold: 1}, nil), "duplicated admin ID")
+	assert.NotNil(n.SetAdmins(&AdminSet{Admins: ids[:1], Threshold: 2}, nil), "threshold too high")
+	// set by master alone for the first time
+	assert.Nil(n.SetAdmins(set1, nil))
+
+	set2 := &AdminSet{Admins: ids[2:], Threshold: 1}
+	subject := adminSubject(n, set1, set2)
+	assert.NotNil(n.SetAdmins(set2, nil), "approvals required")
+	assert.NotNil(n.SetAdmins(set2, approve(subject, keys[0])), "below threshold")
+
+	dup := approve(subject, keys[0])
+	dup.Signatures = append(dup.Signatures, dup.Signatures[0])
+	assert.NotNil(n.SetAdmins(set2, dup), "duplicated admin counted once")
+	assert.NotNil(n.SetAdmins(set2, approve(subject, keys[0], keys[3])), "not an admin")
+
+	expired := subject
+	expired.Expiry = time.Now().Add(-time.Minute).Unix()
+	assert.NotNil(n.SetAdmins(set2, approve(expired, keys[0], keys[1])), "expired")
+
+	mismatched := adminSubject(n, set1, &AdminSet{Admins: ids[1:], Threshold: 1})
+	assert.NotNil(n.SetAdmins(set2, approve(mismatched, keys[0], keys[1])), "subject mismatched")
+	stale := adminSubject(n, nil, set2)
+	assert.NotNil(n.SetAdmins(set2, approve(stale, keys[0], keys[1])), "base mismatched")
+
+	unexpired := subject
+	unexpired.Expiry = time.Now().Add(time.Minute).Unix()
+	assert.Nil(n.SetAdmins(set2, approve(unexpired, keys[0], keys[1])))
+	admins, _ := n.Admins()
+	assert.Equal(set2, admins)
+
+	// rotated admins take over
+	set3 := &AdminSet{Admins: ids[:1], Threshold: 1}
+	subject = adminSubject(n, set2, set3)
+	assert.NotNil(n.SetAdmins(set3, approve(subject, keys[0], keys[1])), "old admins")
+	assert.Nil(n.SetAdmins(set3, approve(subject, keys[3])))
+	admins, _ = n.Admins()
+	assert.Equal(set3, admins)
+}
+
+func TestGCApproval(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServer(Options{})
+	defer s.Close()
+
+	admin, _ := crypto.GenerateIdentity()
+	assert.Nil(s.node.SetAdmins(&AdminSet{Admins: []string{admin.ID()}, Threshold: 1}, nil))
+
+	rpc := s.rpc(s.master)
+	epoch := time.Now().UnixNano()
+	_, err := rpc.MarkGC(epoch)
+	assert.Nil(err, "mark deletes nothing")
+
+	sweepReq := &GCSweepRequest{Grace: MinGCGrace, Nonce: "nonce"}
+	subject := ApprovalSubject{
+		ClusterID:   s.node.ClusterID(),
+		Action:      ActionGC,
+		ContentHash: sweepReq.Hash().ToHex(),
+		Expiry:      time.Now().Add(time.Minute).Unix(),
+	}
+	_, err = rpc.SweepGC(epoch, sweepReq)
+	assert.NotNil(err, "approvals required")
+	_, err = rpc.WithApprovals(approve(subject, admin)).SweepGC(epoch, &GCSweepRequest{Grace: 2 * MinGCGrace, Nonce: "nonce"})
+	assert.NotNil(err, "approved another grace")
+	_, err = rpc.WithApprovals(approve(subject, s.master)).SweepGC(epoch, sweepReq)
+	assert.NotNil(err, "master is not admin")
+	unexpiring := subject
+	unexpiring.Expiry = 0
+	_, err = rpc.WithApprovals(approve(unexpiring, admin)).SweepGC(epoch, sweepReq)
+	assert.NotNil(err, "approvals should expire")
+
+	noNonce := &GCSweepRequest{Grace: MinGCGrace}
+	noNonceSubject := subject
+	noNonceSubject.ContentHash = noNonce.Hash().ToHex()
+	_, err = rpc.WithApprovals(approve(noNonceSubject, admin)).SweepGC(epoch, noNonce)
+	assert.NotNil(err, "nonce required")
+
+	_, err = rpc.WithApprovals(approve(subject, admin)).SweepGC(epoch, sweepReq)
+	assert.Nil(err)
+
+	next := epoch + 1
+	_, err = rpc.MarkGC(next)
+	assert.Nil(err)
+	_, err = rpc.WithApprovals(approve(subject, admin)).SweepGC(next, sweepReq)
+	assert.NotNil(err, "approvals used for another epoch")
+
+	// approvals are not needed without admins
+	n, _ := newTestNode(Options{})
+	ctx := context.Background()
+	n.MarkGC(ctx, epoch)
+	_, err = n.SweepGC(ctx, epoch, MinGCGrace)
+	assert.Nil(err)
+}
+
+func TestAdminActionsUninvited(t *testing.T) {
+	assert := assert.New(t)
+	n, store := newTestNode(Options{})
+	master, _ := crypto.GenerateIdentity()
+	s := &testServer{n, store, master, httptest.NewServer(NewHTTPHandler(n))}
+	defer s.Close()
+
+	signer := testSigner{identity: master}
+	req := s.newSignedRequest(http.MethodPut, "/node/admins", signer, []byte("{}"), time.Now())
+	assert.Equal(http.StatusForbidden, doStatus(req))
+	req = s.newSignedRequest(http.MethodPost, "/node/gc/1?action=sweep", signer, []byte("{}"), time.Now())
+	assert.Equal(http.StatusForbidden, doStatus(req))
+}
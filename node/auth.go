@@ -0,0 +1,200 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/crypto"
+	"github.com/vechain/solidb/spec"
+	"github.com/vechain/solidb/specmgr"
+	"github.com/vechain/solidb/utils/httpx"
+)
+
+const clusterIDHeaderKey = "x-solidb-cluster-id"
+
+var errPeerBodyTooLarge = httpx.Error(errors.New("body too large"), http.StatusRequestEntityTooLarge)
+
+// body limits of internal requests, checked before the body is read for signature
+const (
+	noBody = 0
+	// maxBlobBodyLen a single blob
+	maxBlobBodyLen = blob.DataLenHardLimit
+	// maxKeysBodyLen a batch of keys in JSON
+	maxKeysBodyLen = 1 << 20
+	// maxBatchBodyLen a full batch of blobs in stream
+	maxBatchBodyLen = (MaxBatchKeys + 1) * (blob.DataLenHardLimit + 1024)
+)
+
+// Signer identity signing internal requests, the node itself or the master.
+type Signer interface {
+	Identity() *crypto.Identity
+	ClusterID() string
+}
+
+// Identity returns identity of node, which signs requests to other nodes.
+func (n *Node) Identity() *crypto.Identity {
+	return n.identity
+}
+
+// peerRPC returns RPC to node of entry, signed by this node
+func (n *Node) peerRPC(ctx context.Context, entry spec.Entry) *RPC {
+	return NewRPC().WithContext(ctx).WithAddr(entry.Addr).WithSigner(n, entry.ID)
+}
+
+// isPeer returns whether id is the master or a node in known specs
+func (n *Node) isPeer(id string) (bool, error) {
+	if id == n.MasterID() {
+		return true, nil
+	}
+	peers, err := n.peerSet()
+	if err != nil {
+		return false, err
+	}
+	return peers[id], nil
+}
+
+// peerSet returns IDs of nodes in newest, synced and approved specs.
+// It's cached until specs are committed or tagged.
+func (n *Node) peerSet() (map[string]bool, error) {
+	n.peersMu.RLock()
+	peers := n.peers
+	n.peersMu.RUnlock()
+	if peers != nil {
+		return peers, nil
+	}
+
+	n.peersMu.Lock()
+	defer n.peersMu.Unlock()
+	if n.peers != nil {
+		return n.peers, nil
+	}
+	newest, err := n.specMgr.GetNewest()
+	if err != nil {
+		return nil, err
+	}
+	specs := []*spec.Spec{newest.V}
+	for _, tag := range []string{specmgr.TagSynced, specmgr.TagApproved} {
+		s, err := n.specMgr.GetByTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, s.V)
+	}
+	peers = make(map[string]bool)
+	for _, s := range specs {
+		if s == nil {
+			continue
+		}
+		for _, entry := range s.SAT.Entries {
+			peers[entry.ID] = true
+		}
+	}
+	n.peers = peers
+	return peers, nil
+}
+
+// commitSpec commits spec, and drops cached peer set
+func (n *Node) commitSpec(s spec.Spec) error {
+	defer n.resetPeers()
+	return n.specMgr.Commit(s)
+}
+
+// tagSpec tags spec by revision, and drops cached peer set
+func (n *Node) tagSpec(revision int, tag string) error {
+	defer n.resetPeers()
+	return n.specMgr.Tag(revision, tag)
+}
+
+func (n *Node) resetPeers() {
+	n.peersMu.Lock()
+	defer n.peersMu.Unlock()
+	n.peers = nil
+}
+
+// authenticatePeer verify request is signed by a peer of the same cluster, targeting this node.
+// The signer is checked by headers first, so that body of unknown signers is never read.
+// Nonces are consumed only for requests with side effect, as reads are safe to replay.
+func (n *Node) authenticatePeer(req *http.Request, maxBodyLen int64) error {
+	clusterID := n.ClusterID()
+	if clusterID == "" {
+		return errors.New("not in cluster")
+	}
+	if req.Header.Get(clusterIDHeaderKey) != clusterID {
+		return errors.New("not of the cluster")
+	}
+	targetID := req.Header.Get(targetIDHeaderKey)
+	if targetID != n.ID() {
+		return errors.New("not the target")
+	}
+	sig, err := hex.DecodeString(req.Header.Get(signatureHeaderKey))
+	if err != nil {
+		return err
+	}
+	signerID, err := crypto.SignerID(sig)
+	if err != nil {
+		return err
+	}
+	ok, err := n.isPeer(signerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("unknown signer")
+	}
+	params, err := parseSignedParams(req.Header)
+	if err != nil {
+		return err
+	}
+	if err := params.checkFresh(time.Now()); err != nil {
+		return err
+	}
+
+	if req.ContentLength > maxBodyLen {
+		return errPeerBodyTooLarge
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(req.Body, maxBodyLen+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > maxBodyLen {
+		return errPeerBodyTooLarge
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	hash := signingHash(clusterID, targetID, req.Method, req.RequestURI, params, data)
+	sid, err := crypto.RecoverID(hash, sig)
+	if err != nil {
+		return err
+	}
+	if sid != signerID {
+		return errors.New("unknown signer")
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return n.consumeNonce(sid, params)
+	}
+	return nil
+}
+
+// peerOnly wraps handler of internal requests, which should be signed by peers, with body up to maxBodyLen.
+// If allowLoopback is true, anonymous requests from loopback are allowed too.
+func (n *Node) peerOnly(f httpx.HandlerFunc, maxBodyLen int64, allowLoopback bool) httpx.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) error {
+		if allowLoopback && isLoopback(req) && req.Header.Get(signatureHeaderKey) == "" {
+			return f(w, req)
+		}
+		if err := n.authenticatePeer(req, maxBodyLen); err != nil {
+			if err == errPeerBodyTooLarge || err == errTooManyNonces {
+				return err
+			}
+			return httpx.Error(err, http.StatusUnauthorized)
+		}
+		return f(w, req)
+	}
+}
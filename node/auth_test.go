@@ -0,0 +1,73 @@
+package node_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/crypto"
+	. "github.com/vechain/solidb/node"
+)
+
+func TestAuthenticatePeer(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServer(Options{})
+	defer s.Close()
+
+	key, _ := crypto.GenerateIdentity()
+	peer := testSigner{key, s.node.ClusterID()}
+	rpc := NewRPC().WithAddr(s.server.Listener.Addr().String()).WithSigner(peer, s.node.ID())
+	b := blob.New([]byte("data"))
+
+	_, err := rpc.HasBlob(b.Key())
+	assert.NotNil(err, "signer outside the specs")
+
+	assert.Nil(s.node.ProposeSpec(testSpec(1, s.node.ID(), key.ID())))
+	_, err = rpc.HasBlob(b.Key())
+	assert.Nil(err, "listed by newest spec")
+
+	foreign := NewRPC().WithAddr(s.server.Listener.Addr().String()).WithSigner(testSigner{key, "foreign"}, s.node.ID())
+	_, err = foreign.HasBlob(b.Key())
+	assert.NotNil(err, "foreign cluster ID")
+	req := s.newSignedRequest(http.MethodPost, "/node/blobs", testSigner{key, "foreign"}, b.Data(), time.Now())
+	req.Header.Set("x-solidb-cluster-id", s.node.ClusterID())
+	assert.Equal(http.StatusUnauthorized, doStatus(req), "cluster ID is signed")
+
+	req = s.newSignedRequest(http.MethodPost, "/node/blobs", peer, b.Data(), time.Now())
+	replayed, _ := http.NewRequest(req.Method, req.URL.String(), bytes.NewReader(b.Data()))
+	replayed.Header = req.Header
+	assert.Equal(http.StatusOK, doStatus(req))
+	assert.Equal(http.StatusUnauthorized, doStatus(replayed), "replayed nonce")
+
+	req = s.newSignedRequest(http.MethodPost, "/node/blobs", peer, b.Data(), time.Now().Add(-6*time.Minute))
+	assert.Equal(http.StatusUnauthorized, doStatus(req), "stale timestamp")
+
+	large := make([]byte, blob.DataLenHardLimit+1)
+	req = s.newSignedRequest(http.MethodPost, "/node/blobs", peer, large, time.Now())
+	assert.Equal(http.StatusRequestEntityTooLarge, doStatus(req))
+	other, _ := crypto.GenerateIdentity()
+	req = s.newSignedRequest(http.MethodPost, "/node/blobs:batchPut", testSigner{other, s.node.ClusterID()}, large, time.Now())
+	assert.Equal(http.StatusUnauthorized, doStatus(req), "unknown signer")
+}
+
+func TestPeerOnlyLoopback(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServer(Options{})
+	defer s.Close()
+	handler := NewHTTPHandler(s.node)
+
+	serve := func(path, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(http.StatusOK, serve("/node/status", "127.0.0.1:1234"))
+	assert.Equal(http.StatusUnauthorized, serve("/node/status", "10.0.0.1:1234"), "anonymous remote")
+	assert.Equal(http.StatusUnauthorized, serve("/node/blobs?prefix=", "127.0.0.1:1234"), "loopback only for status")
+}
@@ -0,0 +1,107 @@
+package node
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vechain/solidb/utils/fpath"
+	"github.com/vechain/solidb/utils/httpx"
+)
+
+const (
+	defaultHighWatermark = 0.95
+	// interval to measure size of store dir
+	usageMeasureInterval = 10 * time.Second
+)
+
+var errInsufficientStorage = httpx.Error(errors.New("insufficient storage"), http.StatusInsufficientStorage)
+
+// IsInsufficientStorage returns whether the RPC error is caused by node running out of capacity
+func IsInsufficientStorage(err error) bool {
+	return httpx.IsResponseStatus(err, http.StatusInsufficientStorage)
+}
+
+// capacityEnabled returns whether store usage is tracked against capacity
+func (n *Node) capacityEnabled() bool {
+	return n.options.Capacity > 0 && n.options.StoreDir != ""
+}
+
+func (n *Node) highWatermark() int64 {
+	ratio := n.options.HighWatermark
+	if ratio <= 0 || ratio > 1 {
+		ratio = defaultHighWatermark
+	}
+	return int64(float64(n.options.Capacity) * ratio)
+}
+
+// measureUsage measure size of store dir
+func (n *Node) measureUsage() error {
+	size, err := fpath.SizeOfDir(n.options.StoreDir)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&n.usedBytes, size)
+	return nil
+}
+
+// addUsage account bytes written since last measured
+func (n *Node) addUsage(size int) {
+	atomic.AddInt64(&n.usedBytes, int64(size))
+}
+
+// admitWrite returns errInsufficientStorage if the store passes high watermark
+func (n *Node) admitWrite() error {
+	if n.capacityEnabled() && atomic.LoadInt64(&n.usedBytes) >= n.highWatermark() {
+		return errInsufficientStorage
+	}
+	return nil
+}
+
+// storageStatus returns usage of store, nil if not tracked
+func (n *Node) storageStatus() *StorageStatus {
+	if !n.capacityEnabled() {
+		return nil
+	}
+	used := atomic.LoadInt64(&n.usedBytes)
+	free := n.options.Capacity - used
+	if free < 0 {
+		free = 0
+	}
+	return &StorageStatus{
+		Capacity:      n.options.Capacity,
+		Used:          used,
+		Free:          free,
+		HighWatermark: n.highWatermark(),
+		Full:          used >= n.highWatermark(),
+	}
+}
+
+// usageLoop measure store usage periodically
+func (n *Node) usageLoop(ctx context.Context) {
+	log.Info("enter usage loop")
+
+	ticker := time.NewTicker(usageMeasureInterval)
+	defer func() {
+		if err := recover(); err != nil {
+			log.Warnln("usage loop recovered:", err)
+		}
+		ticker.Stop()
+		n.wg.Done()
+		log.Info("leave usage loop")
+	}()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := n.measureUsage(); err != nil {
+				log.Errorf("measure usage: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
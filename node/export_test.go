@@ -0,0 +1,21 @@
+package node
+
+import (
+	"net/http"
+	"time"
+)
+
+// SignRequestAt sign request as RPC does, but with timestamp of the given time
+func SignRequestAt(req *http.Request, signer Signer, targetID string, body []byte, at time.Time) error {
+	p, err := newSignedParams()
+	if err != nil {
+		return err
+	}
+	p.timestamp = at.Unix()
+	return signRequest(req, signer, targetID, p, body)
+}
+
+// SetNonceCapacity set max count of nonces cached in memory
+func (n *Node) SetNonceCapacity(capacity int) {
+	n.nonces.capacity = capacity
+}
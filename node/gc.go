@@ -0,0 +1,490 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/crypto"
+	"github.com/vechain/solidb/kv"
+	"github.com/vechain/solidb/spec"
+	"github.com/vechain/solidb/specmgr"
+)
+
+var (
+	// gcEpochKey key to store epoch of the completed GC mark phase
+	gcEpochKey = kv.NewTable("gc-epoch", ".gc-epoch").Key(nil)
+	// gcMarkingKey key to store epoch of the latest GC mark phase, written before pins are iterated.
+	// Blobs pinned during a GC run are reached in the epoch, so that their children survive the sweep.
+	gcMarkingKey = kv.NewTable("gc-marking", ".gc-marking").Key(nil)
+	// gcApprovalTable records epoch swept by each approved sweep request, keyed by its nonce
+	gcApprovalTable = kv.NewTable("gc-approval", ".gc-approvals/")
+)
+
+const (
+	gcBatchLen = 1000
+	// MinGCGrace min grace period of GC sweep
+	MinGCGrace = time.Hour
+	// maxGCRunTime max time from the epoch to sweep, during which pins are reached in the epoch
+	maxGCRunTime = time.Hour
+)
+
+// Hash returns hash of request marshaled into JSON, which is approved by admins
+func (r *GCSweepRequest) Hash() crypto.Hash {
+	data, _ := json.Marshal(r)
+	return crypto.HashSum(data)
+}
+
+// useGCApproval binds approved sweep request to the epoch, so that approvals can't sweep another epoch.
+// Sweep requests need no nonce without admins.
+func (n *Node) useGCApproval(r *GCSweepRequest, epoch int64) error {
+	admins, err := n.Admins()
+	if err != nil {
+		return err
+	}
+	if admins == nil {
+		return nil
+	}
+	if r.Nonce == "" {
+		return errors.New("nonce required")
+	}
+
+	n.nonceMu.Lock()
+	defer n.nonceMu.Unlock()
+
+	table := gcApprovalTable.Store(n.store)
+	used, err := table.Get([]byte(r.Nonce))
+	if err != nil {
+		return err
+	}
+	if used.V != nil {
+		if decodeInt64(used.V) != epoch {
+			return errors.New("approvals used for another epoch")
+		}
+		return nil
+	}
+	return table.Put([]byte(r.Nonce), encodeInt64(epoch))
+}
+
+func encodeInt64(v int64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	return b[:]
+}
+
+func decodeInt64(b []byte) int64 {
+	if len(b) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// stableSpec returns approved spec, or error if a spec transition is in flight.
+func (n *Node) stableSpec() (*spec.Spec, error) {
+	approved, err := n.specMgr.GetByTag(specmgr.TagApproved)
+	if err != nil {
+		return nil, err
+	}
+	if approved.V == nil {
+		return nil, errors.New("no approved spec")
+	}
+	newest, err := n.specMgr.GetNewest()
+	if err != nil {
+		return nil, err
+	}
+	if newest.V == nil || newest.V.Revision != approved.V.Revision {
+		return nil, errors.New("spec transition in flight")
+	}
+	return approved.V, nil
+}
+
+// PinBlob pin a blob with label.
+// If a GC run is in progress, blobs reachable from the pinned one are marked reached in its epoch.
+func (n *Node) PinBlob(ctx context.Context, key blob.Key, label string) error {
+	batch := n.store.NewBatch()
+	if err := blobio.PinBlob(batch, key, label); err != nil {
+		return err
+	}
+	if err := blobio.UnmarkBlob(batch, key, blobio.GCCandidateMark); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+
+	// the pin is written before the marking epoch read, while mark phase does the reverse,
+	// so the pin is either iterated by mark phase, or reached here
+	marking, err := n.store.Get(gcMarkingKey)
+	if err != nil {
+		return err
+	}
+	epoch := decodeInt64(marking.V)
+	if epoch == 0 || time.Since(time.Unix(0, epoch)) > maxGCRunTime+signatureWindow {
+		return nil
+	}
+	approved, err := n.stableSpec()
+	if err != nil {
+		// sweep is refused anyway
+		return nil
+	}
+	m := newGCMarker(ctx, n, epoch, approved.SAT)
+	if err := m.reach(key, gcRoot); err != nil {
+		return err
+	}
+	return m.flushAll()
+}
+
+// UnpinBlob remove pin with label.
+func (n *Node) UnpinBlob(key blob.Key, label string) error {
+	return blobio.UnpinBlob(n.store, key, label)
+}
+
+// MarkReached mark blobs reachable in GC epoch.
+func (n *Node) MarkReached(epoch int64, keys []blob.Key) error {
+	batch := n.store.NewBatch()
+	value := encodeInt64(epoch)
+	for _, key := range keys {
+		if err := blobio.MarkBlobWithValue(batch, key, blobio.GCReachedMark, value); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// MarkGC runs GC mark phase. All blobs reachable from local pins are marked on nodes they belong to.
+func (n *Node) MarkGC(ctx context.Context, epoch int64) (*GCMarkResponse, error) {
+	approved, err := n.stableSpec()
+	if err != nil {
+		return nil, err
+	}
+	if err := n.store.Put(gcMarkingKey, encodeInt64(epoch)); err != nil {
+		return nil, err
+	}
+	m := newGCMarker(ctx, n, epoch, approved.SAT)
+
+	iter, err := blobio.NewPinIterator(n.store, "")
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Release()
+
+	var resp GCMarkResponse
+	for iter.Next() {
+		pin, err := iter.Pin()
+		if err != nil {
+			return nil, err
+		}
+		resp.PinCount++
+		if err := m.reach(pin.Key, gcRoot); err != nil {
+			return nil, err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	if err := m.flushAll(); err != nil {
+		return nil, err
+	}
+	if err := n.store.Put(gcEpochKey, encodeInt64(epoch)); err != nil {
+		return nil, err
+	}
+	resp.ReachedCount = len(m.visited)
+	log.Infof("gc mark: epoch %d, %d pins, %d reached", epoch, resp.PinCount, resp.ReachedCount)
+	return &resp, nil
+}
+
+// SweepGC runs GC sweep phase. Local blobs neither reached in epoch nor pinned become candidates,
+// and deleted once they stay candidates longer than grace period.
+// Writing a blob clears its candidate mark, so blobs written within grace period are never deleted.
+func (n *Node) SweepGC(ctx context.Context, epoch int64, grace time.Duration) (*GCSweepResponse, error) {
+	if grace < MinGCGrace {
+		return nil, errors.Errorf("grace should be at least %v", MinGCGrace)
+	}
+	approved, err := n.stableSpec()
+	if err != nil {
+		return nil, err
+	}
+	marked, err := n.store.Get(gcEpochKey)
+	if err != nil {
+		return nil, err
+	}
+	if decodeInt64(marked.V) != epoch {
+		return nil, errors.New("epoch not marked")
+	}
+	// pins made long after the epoch are no longer reached in it
+	if time.Since(time.Unix(0, epoch)) > maxGCRunTime {
+		return nil, errors.New("epoch expired")
+	}
+
+	epochValue := encodeInt64(epoch)
+	now := time.Now()
+
+	batch := n.store.NewBatch()
+	var deleted []blob.Key
+	// flush writes the batch, if the spec stays unchanged
+	flush := func() error {
+		current, err := n.stableSpec()
+		if err != nil {
+			return err
+		}
+		if current.Revision != approved.Revision {
+			return errors.New("spec changed")
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+		for _, key := range deleted {
+			n.cache.Remove(key)
+		}
+		deleted = deleted[:0]
+		return nil
+	}
+
+	iter, err := blobio.NewBlobIterator(n.store, "")
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Release()
+
+	var resp GCSweepResponse
+	for iter.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		key, err := iter.Key()
+		if err != nil {
+			return nil, err
+		}
+		resp.BlobCount++
+
+		candidate, err := blobio.GetBlobMark(n.store, *key, blobio.GCCandidateMark)
+		if err != nil {
+			return nil, err
+		}
+		reached, err := blobio.GetBlobMark(n.store, *key, blobio.GCReachedMark)
+		if err != nil {
+			return nil, err
+		}
+		alive := bytes.Equal(reached.V, epochValue)
+		if !alive {
+			if alive, err = blobio.IsBlobPinned(n.store, *key); err != nil {
+				return nil, err
+			}
+		}
+
+		if alive {
+			if candidate.V != nil {
+				if err := blobio.UnmarkBlob(batch, *key, blobio.GCCandidateMark); err != nil {
+					return nil, err
+				}
+			}
+		} else if candidate.V == nil {
+			if err := blobio.MarkBlobWithValue(batch, *key, blobio.GCCandidateMark, encodeInt64(now.UnixNano())); err != nil {
+				return nil, err
+			}
+			resp.CandidateCount++
+		} else if now.Sub(time.Unix(0, decodeInt64(candidate.V))) < grace {
+			resp.CandidateCount++
+		} else {
+			if err := sweepBlob(batch, *key); err != nil {
+				return nil, err
+			}
+			deleted = append(deleted, *key)
+			resp.DeletedCount++
+		}
+
+		if batch.Len() >= gcBatchLen {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	// reached marks are only valid for this epoch
+	markIter := blobio.NewMarkIterator(n.store, blobio.GCReachedMark)
+	defer markIter.Release()
+	for markIter.Next() {
+		key, err := markIter.BlobKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := blobio.UnmarkBlob(batch, *key, blobio.GCReachedMark); err != nil {
+			return nil, err
+		}
+		if batch.Len() >= gcBatchLen {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := markIter.Error(); err != nil {
+		return nil, err
+	}
+	// prevent sweeping again without marking
+	if err := batch.Delete(gcEpochKey); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	log.Infof("gc sweep: epoch %d, %d blobs, %d candidates, %d deleted",
+		epoch, resp.BlobCount, resp.CandidateCount, resp.DeletedCount)
+	if resp.DeletedCount > 0 {
+		// reclaim space of deleted blobs
+		n.requestCompact()
+	}
+	return &resp, nil
+}
+
+// sweepBlob delete blob along with its meta and marks
+func sweepBlob(w kv.Writer, key blob.Key) error {
+	if err := blobio.DeleteBlob(w, key); err != nil {
+		return err
+	}
+	if err := blobio.DeleteBlobMeta(w, key); err != nil {
+		return err
+	}
+	if err := blobio.UnmarkBlob(w, key, blobio.GCCandidateMark); err != nil {
+		return err
+	}
+	return blobio.UnmarkBlob(w, key, blobio.FaultBlobMark)
+}
+
+// kinds of reached blob
+const (
+	gcRoot = iota
+	gcManifest
+	gcChunk
+)
+
+// gcMarker traverses blobs reachable from pins
+type gcMarker struct {
+	node    *Node
+	ctx     context.Context
+	epoch   int64
+	sat     spec.SAT
+	visited map[blob.Key]bool
+	// pending reached keys by entry ID
+	pending map[string][]blob.Key
+}
+
+func newGCMarker(ctx context.Context, n *Node, epoch int64, sat spec.SAT) *gcMarker {
+	return &gcMarker{
+		node:    n,
+		ctx:     ctx,
+		epoch:   epoch,
+		sat:     sat,
+		visited: make(map[blob.Key]bool),
+		pending: make(map[string][]blob.Key),
+	}
+}
+
+func (m *gcMarker) reach(key blob.Key, kind int) error {
+	if m.visited[key] {
+		return nil
+	}
+	m.visited[key] = true
+	for _, entry := range m.sat.Locate(key.ToHex()) {
+		m.pending[entry.ID] = append(m.pending[entry.ID], key)
+		if len(m.pending[entry.ID]) >= gcBatchLen {
+			if err := m.flush(entry); err != nil {
+				return err
+			}
+		}
+	}
+	if kind == gcChunk {
+		return nil
+	}
+
+	data, _, err := m.node.fetchBlob(m.ctx, key, m.sat.Locate(key.ToHex()))
+	if err != nil {
+		return err
+	}
+	if data == nil || !blob.IsManifest(data.Data()) {
+		if kind == gcManifest {
+			return errors.Errorf("manifest %s unavailable", key.ToHex())
+		}
+		return nil
+	}
+	manifest, err := blob.ParseManifest(data.Data())
+	if err != nil {
+		return err
+	}
+	childKind := gcChunk
+	if manifest.Level > 0 {
+		childKind = gcManifest
+	}
+	for _, child := range manifest.Keys {
+		if err := m.reach(child, childKind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush send pending reached keys to the entry
+func (m *gcMarker) flush(entry spec.Entry) error {
+	keys := m.pending[entry.ID]
+	if len(keys) == 0 {
+		return nil
+	}
+	delete(m.pending, entry.ID)
+	if entry.ID == m.node.ID() {
+		return m.node.MarkReached(m.epoch, keys)
+	}
+	rpc := m.node.peerRPC(m.ctx, entry)
+	return rpc.MarkReached(m.epoch, keys)
+}
+
+// flushAll send pending reached keys to all entries
+func (m *gcMarker) flushAll() error {
+	for _, entry := range m.sat.Entries {
+		if err := m.flush(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchBlob get blob and its meta from local store, or from nodes of entries.
+func (n *Node) fetchBlob(ctx context.Context, key blob.Key, entries []spec.Entry) (*blob.Blob, *blobio.Meta, error) {
+	local, err := blobio.GetBlob(n.store, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if local.V != nil {
+		meta, err := blobio.GetBlobMeta(n.store, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		return local.V, meta, nil
+	}
+
+	var lastErr error
+	for _, entry := range entries {
+		if entry.ID == n.ID() {
+			continue
+		}
+		remote, meta, err := n.peerRPC(ctx, entry).GetBlobWithMeta(key)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if remote.V != nil {
+			return remote.V, meta, nil
+		}
+	}
+	return nil, nil, lastErr
+}
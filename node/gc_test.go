@@ -0,0 +1,149 @@
+package node_test
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/kv"
+	. "github.com/vechain/solidb/node"
+)
+
+func putBlobs(store kv.Store, data ...string) []blob.Key {
+	var keys []blob.Key
+	for _, d := range data {
+		b := blob.New([]byte(d))
+		blobio.PutBlob(store, b)
+		keys = append(keys, b.Key())
+	}
+	return keys
+}
+
+// putManifest put a manifest of chunks, and returns key of the manifest
+func putManifest(store kv.Store, chunks ...blob.Key) blob.Key {
+	m := blob.Manifest{ChunkSize: 1, Fanout: blob.ManifestMaxFanout, Size: uint64(len(chunks)), Keys: chunks}
+	b := blob.New(m.Encode())
+	blobio.PutBlob(store, b)
+	return b.Key()
+}
+
+// ageCandidate make the blob a GC candidate found age ago
+func ageCandidate(store kv.Store, key blob.Key, age time.Duration) {
+	var v [8]byte
+	binary.BigEndian.PutUint64(v[:], uint64(time.Now().Add(-age).UnixNano()))
+	blobio.MarkBlobWithValue(store, key, blobio.GCCandidateMark, v[:])
+}
+
+func hasBlob(store kv.Store, key blob.Key) bool {
+	has, _ := blobio.HasBlob(store, key)
+	return has
+}
+
+func TestGC(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	n, store := newTestNode(Options{})
+
+	keys := putBlobs(store, "pinned", "garbage", "chunk")
+	pinned, garbage, chunk := keys[0], keys[1], keys[2]
+	manifest := putManifest(store, chunk)
+	assert.Nil(n.PinBlob(ctx, pinned, "a"))
+	assert.Nil(n.PinBlob(ctx, manifest, "b"))
+
+	epoch := time.Now().UnixNano()
+	_, err := n.SweepGC(ctx, epoch, MinGCGrace)
+	assert.NotNil(err, "not marked")
+
+	markResp, err := n.MarkGC(ctx, epoch)
+	assert.Nil(err)
+	assert.Equal(&GCMarkResponse{PinCount: 2, ReachedCount: 3}, markResp)
+
+	_, err = n.SweepGC(ctx, epoch, MinGCGrace-time.Second)
+	assert.NotNil(err, "grace too short")
+
+	sweepResp, err := n.SweepGC(ctx, epoch, MinGCGrace)
+	assert.Nil(err)
+	assert.Equal(&GCSweepResponse{BlobCount: 4, CandidateCount: 1}, sweepResp)
+
+	_, err = n.SweepGC(ctx, epoch, MinGCGrace)
+	assert.NotNil(err, "sweep again without mark")
+
+	// candidate younger than grace survives
+	epoch = time.Now().UnixNano()
+	n.MarkGC(ctx, epoch)
+	sweepResp, err = n.SweepGC(ctx, epoch, MinGCGrace)
+	assert.Nil(err)
+	assert.Equal(&GCSweepResponse{BlobCount: 4, CandidateCount: 1}, sweepResp)
+	assert.True(hasBlob(store, garbage))
+
+	ageCandidate(store, garbage, 2*time.Hour)
+	epoch = time.Now().UnixNano()
+	n.MarkGC(ctx, epoch)
+	sweepResp, err = n.SweepGC(ctx, epoch, MinGCGrace)
+	assert.Nil(err)
+	assert.Equal(&GCSweepResponse{BlobCount: 4, DeletedCount: 1}, sweepResp)
+	assert.False(hasBlob(store, garbage))
+	for _, key := range []blob.Key{pinned, chunk, manifest} {
+		assert.True(hasBlob(store, key))
+	}
+
+	// unpinned manifest is collected along with its chunk
+	assert.Nil(n.UnpinBlob(manifest, "b"))
+	ageCandidate(store, manifest, 2*time.Hour)
+	ageCandidate(store, chunk, 2*time.Hour)
+	epoch = time.Now().UnixNano()
+	n.MarkGC(ctx, epoch)
+	sweepResp, err = n.SweepGC(ctx, epoch, MinGCGrace)
+	assert.Nil(err)
+	assert.Equal(2, sweepResp.DeletedCount)
+	assert.True(hasBlob(store, pinned))
+}
+
+func TestGCPinAfterMark(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	n, store := newTestNode(Options{})
+
+	chunk := putBlobs(store, "chunk")[0]
+	manifest := putManifest(store, chunk)
+	ageCandidate(store, chunk, 2*time.Hour)
+	ageCandidate(store, manifest, 2*time.Hour)
+
+	epoch := time.Now().UnixNano()
+	n.MarkGC(ctx, epoch)
+	// pinned between mark and sweep
+	assert.Nil(n.PinBlob(ctx, manifest, "late"))
+	sweepResp, err := n.SweepGC(ctx, epoch, MinGCGrace)
+	assert.Nil(err)
+	assert.Equal(0, sweepResp.DeletedCount)
+	assert.True(hasBlob(store, chunk))
+	assert.True(hasBlob(store, manifest))
+}
+
+func TestGCRefused(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	n, _ := newTestNode(Options{})
+
+	// epoch too old
+	epoch := time.Now().Add(-2 * time.Hour).UnixNano()
+	_, err := n.MarkGC(ctx, epoch)
+	assert.Nil(err)
+	_, err = n.SweepGC(ctx, epoch, MinGCGrace)
+	assert.NotNil(err)
+
+	epoch = time.Now().UnixNano()
+	_, err = n.MarkGC(ctx, epoch)
+	assert.Nil(err)
+
+	// spec transition in flight
+	assert.Nil(n.ProposeSpec(testSpec(1, n.ID())))
+	_, err = n.SweepGC(ctx, epoch, MinGCGrace)
+	assert.NotNil(err)
+	_, err = n.MarkGC(ctx, time.Now().UnixNano())
+	assert.NotNil(err)
+}
@@ -1,20 +1,26 @@
 package node
 
 import (
+	"bytes"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"github.com/vechain/solidb/backup"
 	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobcache"
 	"github.com/vechain/solidb/blobio"
 	"github.com/vechain/solidb/crypto"
 	"github.com/vechain/solidb/spec"
+	"github.com/vechain/solidb/specmgr"
 	"github.com/vechain/solidb/utils/httpx"
 )
 
@@ -22,8 +28,9 @@ const (
 	// HTTPPathPrefix path prefix
 	HTTPPathPrefix = "/node/"
 
-	signatureHeaderKey = "x-solidb-signature"
-	targetIDHeaderKey  = "x-solidb-target-id"
+	signatureHeaderKey   = "x-solidb-signature"
+	targetIDHeaderKey    = "x-solidb-target-id"
+	backupTokenHeaderKey = "x-solidb-backup-token"
 )
 
 // NewHTTPHandler create http handler to expose operations to local node
@@ -32,20 +39,38 @@ func NewHTTPHandler(node *Node) http.Handler {
 
 	sub := router.PathPrefix(HTTPPathPrefix).Subrouter()
 	sub.Methods(http.MethodPost).Path("/invitation").HandlerFunc(httpx.WrapHandlerFunc(node.handleInvite))
+	sub.Methods(http.MethodPost).Path("/master/rotations").HandlerFunc(httpx.WrapHandlerFunc(node.handleRotateMaster))
+	sub.Methods(http.MethodGet).Path("/master/rotations").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handleGetRotations, noBody, false)))
+	sub.Methods(http.MethodPut).Path("/admins").HandlerFunc(httpx.WrapHandlerFunc(node.handleSetAdmins))
 	sub.Methods(http.MethodPost).Path("/specs").HandlerFunc(httpx.WrapHandlerFunc(node.handleProposeSpec))
 	sub.Methods(http.MethodPost).Path("/specs/{revision}").Queries("action", "{action}").HandlerFunc(httpx.WrapHandlerFunc(node.handleSpecAction))
 
-	sub.Methods(http.MethodGet).Path("/status").HandlerFunc(httpx.WrapHandlerFunc(node.handleGetStatus))
-	sub.Methods(http.MethodGet).Path("/status/sync").Queries("revision", "{revision}").HandlerFunc(httpx.WrapHandlerFunc(node.handleGetSyncStatus))
+	sub.Methods(http.MethodGet).Path("/status").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handleGetStatus, noBody, true)))
+	sub.Methods(http.MethodGet).Path("/status/sync").Queries("revision", "{revision}").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handleGetSyncStatus, noBody, true)))
 
-	sub.Methods(http.MethodGet).Path("/blobs/{key}").HandlerFunc(httpx.WrapHandlerFunc(node.handleGetBlob))
-	sub.Methods(http.MethodPost).Path("/blobs").HandlerFunc(httpx.WrapHandlerFunc(node.handlePutBlob))
-	sub.Methods(http.MethodGet).Path("/blobs").Queries("prefix", "{prefix}").HandlerFunc(httpx.WrapHandlerFunc(node.handleGetBlobSlice))
+	sub.Methods(http.MethodGet).Path("/blobs/{key}").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handleGetBlob, noBody, false)))
+	sub.Methods(http.MethodHead).Path("/blobs/{key}").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handleHasBlob, noBody, false)))
+	sub.Methods(http.MethodPost).Path("/blobs").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handlePutBlob, maxBlobBodyLen, false)))
+	sub.Methods(http.MethodGet).Path("/blobs").Queries("prefix", "{prefix}").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handleGetBlobSlice, noBody, false)))
+	sub.Methods(http.MethodPost).Path("/blobs:batchGet").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handleBatchGetBlobs, maxKeysBodyLen, false)))
+	sub.Methods(http.MethodPost).Path("/blobs:batchPut").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handleBatchPutBlobs, maxBatchBodyLen, false)))
+
+	sub.Methods(http.MethodPut).Path("/blobs/{key}/pins/{label}").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handlePinBlob, noBody, false)))
+	sub.Methods(http.MethodDelete).Path("/blobs/{key}/pins/{label}").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handleUnpinBlob, noBody, false)))
+	sub.Methods(http.MethodGet).Path("/metas").Queries("prefix", "{prefix}").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handleGetMetaSlice, noBody, false)))
+	sub.Methods(http.MethodGet).Path("/pins").Queries("prefix", "{prefix}").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handleGetPinSlice, noBody, false)))
+
+	sub.Methods(http.MethodGet).Path("/backup").HandlerFunc(httpx.WrapHandlerFunc(node.handleBackup))
+
+	sub.Methods(http.MethodPost).Path("/gc/reached").HandlerFunc(httpx.WrapHandlerFunc(node.peerOnly(node.handleGCReached, maxKeysBodyLen, false)))
+	sub.Methods(http.MethodPost).Path("/gc/{epoch:[0-9]+}").Queries("action", "{action}").HandlerFunc(httpx.WrapHandlerFunc(node.handleGCAction))
 
 	return router
 }
 
-func (n *Node) handleSignedRequest(req *http.Request, signerID *string) ([]byte, error) {
+// handleSignedRequest verify signature of request, and returns its body.
+// If approval is not nil, the request should also be approved by admins, on subject derived from body.
+func (n *Node) handleSignedRequest(req *http.Request, signerID *string, approval func(body []byte) (*ApprovalSubject, error)) ([]byte, error) {
 	targetID := req.Header.Get(targetIDHeaderKey)
 	if targetID != "" && targetID != n.ID() {
 		return nil, errors.New("not the target")
@@ -55,14 +80,27 @@ func (n *Node) handleSignedRequest(req *http.Request, signerID *string) ([]byte,
 	if err != nil {
 		return nil, err
 	}
+	if *signerID != "" {
+		// reject before reading body
+		if claimed, err := crypto.SignerID(sig); err != nil || claimed != *signerID {
+			return nil, errors.New("not the master")
+		}
+	}
+
+	params, err := parseSignedParams(req.Header)
+	if err != nil {
+		return nil, err
+	}
+	if err := params.checkFresh(time.Now()); err != nil {
+		return nil, err
+	}
 
 	data, err := ioutil.ReadAll(req.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	vdata := append([]byte(targetID+"\n"+req.RequestURI+"\n"), data...)
-	hash := crypto.HashSum(vdata)
+	hash := signingHash(req.Header.Get(clusterIDHeaderKey), targetID, req.Method, req.RequestURI, params, data)
 	sid, err := crypto.RecoverID(hash, sig)
 	if err != nil {
 		return nil, err
@@ -75,12 +113,28 @@ func (n *Node) handleSignedRequest(req *http.Request, signerID *string) ([]byte,
 			return nil, errors.New("not the master")
 		}
 	}
+	if err := n.consumeStoredNonce(sid, params); err != nil {
+		return nil, err
+	}
+	if approval != nil {
+		subject, err := approval(data)
+		if err != nil {
+			return nil, err
+		}
+		approvals, err := approvalsFromHeader(req.Header)
+		if err != nil {
+			return nil, err
+		}
+		if err := n.checkApproved(subject, approvals); err != nil {
+			return nil, err
+		}
+	}
 	return data, nil
 }
 
 func (n *Node) handleInvite(w http.ResponseWriter, req *http.Request) error {
 	signerID := ""
-	data, err := n.handleSignedRequest(req, &signerID)
+	data, err := n.handleSignedRequest(req, &signerID, nil)
 	if err != nil {
 		return httpx.Error(err, http.StatusUnauthorized)
 	}
@@ -90,7 +144,7 @@ func (n *Node) handleInvite(w http.ResponseWriter, req *http.Request) error {
 		return httpx.Error(err, http.StatusBadRequest)
 	}
 
-	if err := n.Invite(signerID, reqBody.InitSpec); err != nil {
+	if err := n.Invite(signerID, reqBody.InitSpec, reqBody.Rotations, reqBody.Admins); err != nil {
 		return err
 	}
 
@@ -99,26 +153,79 @@ func (n *Node) handleInvite(w http.ResponseWriter, req *http.Request) error {
 	})
 }
 
-func (n *Node) handleProposeSpec(w http.ResponseWriter, req *http.Request) error {
-	signerID := n.clusterID
-	data, err := n.handleSignedRequest(req, &signerID)
+func (n *Node) handleRotateMaster(w http.ResponseWriter, req *http.Request) error {
+	// the signer is checked on rotating, since a rotation already applied is accepted from the old master
+	signerID := ""
+	data, err := n.handleSignedRequest(req, &signerID, nil)
 	if err != nil {
 		return httpx.Error(err, http.StatusUnauthorized)
 	}
-	var s spec.Spec
-	if err := json.Unmarshal(data, &s); err != nil {
+	var reqBody RotateMasterRequest
+	if err := json.Unmarshal(data, &reqBody); err != nil {
 		return httpx.Error(err, http.StatusBadRequest)
 	}
-	return n.ProposeSpec(s)
+	if err := n.RotateMaster(signerID, reqBody.Rotations); err != nil {
+		return httpx.Error(err, http.StatusForbidden)
+	}
+	return nil
 }
 
-func (n *Node) handleSpecAction(w http.ResponseWriter, req *http.Request) error {
-	signerID := n.clusterID
-	_, err := n.handleSignedRequest(req, &signerID)
+func (n *Node) handleGetRotations(w http.ResponseWriter, req *http.Request) error {
+	rotations, err := n.Rotations()
 	if err != nil {
+		return err
+	}
+	return httpx.ResponseJSON(w, &RotationsResponse{
+		ClusterID: n.ClusterID(),
+		MasterID:  n.MasterID(),
+		Rotations: rotations,
+	})
+}
+
+func (n *Node) handleSetAdmins(w http.ResponseWriter, req *http.Request) error {
+	signerID := n.MasterID()
+	if signerID == "" {
+		return httpx.Error(errors.New("not invited"), http.StatusForbidden)
+	}
+	data, err := n.handleSignedRequest(req, &signerID, nil)
+	if err != nil {
+		return httpx.Error(err, http.StatusUnauthorized)
+	}
+	var reqBody SetAdminsRequest
+	if err := json.Unmarshal(data, &reqBody); err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+	approvals, err := approvalsFromHeader(req.Header)
+	if err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+	// approvals are verified against admins in effect while replacing
+	if err := n.SetAdmins(&reqBody.Admins, approvals); err != nil {
 		return httpx.Error(err, http.StatusUnauthorized)
 	}
+	return nil
+}
+
+func (n *Node) handleProposeSpec(w http.ResponseWriter, req *http.Request) error {
+	signerID := n.MasterID()
+	var s spec.Spec
+	_, err := n.handleSignedRequest(req, &signerID, func(data []byte) (*ApprovalSubject, error) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		return &ApprovalSubject{
+			Action:      ActionProposeSpec,
+			Revision:    s.Revision,
+			ContentHash: s.Hash().ToHex(),
+		}, nil
+	})
+	if err != nil {
+		return httpx.Error(err, http.StatusUnauthorized)
+	}
+	return n.ProposeSpec(s)
+}
 
+func (n *Node) handleSpecAction(w http.ResponseWriter, req *http.Request) error {
 	vars := mux.Vars(req)
 	revStr := vars["revision"]
 	rev, err := strconv.Atoi(revStr)
@@ -126,6 +233,29 @@ func (n *Node) handleSpecAction(w http.ResponseWriter, req *http.Request) error
 		return httpx.Error(err, http.StatusBadRequest)
 	}
 	action := vars["action"]
+
+	var approval func([]byte) (*ApprovalSubject, error)
+	if action == "approve" {
+		approval = func([]byte) (*ApprovalSubject, error) {
+			s, err := n.specMgr.GetByRevision(rev)
+			if err != nil {
+				return nil, err
+			}
+			if s.V == nil {
+				return nil, errors.New("spec not found")
+			}
+			return &ApprovalSubject{
+				Action:      ActionApproveSpec,
+				Revision:    rev,
+				ContentHash: s.V.Hash().ToHex(),
+			}, nil
+		}
+	}
+	signerID := n.MasterID()
+	if _, err := n.handleSignedRequest(req, &signerID, approval); err != nil {
+		return httpx.Error(err, http.StatusUnauthorized)
+	}
+
 	if action == "approve" {
 		return n.ApproveSpec(rev)
 	} else if action == "sync" {
@@ -165,46 +295,108 @@ func (n *Node) handleGetBlob(w http.ResponseWriter, req *http.Request) error {
 		return httpx.Error(err, http.StatusBadRequest)
 	}
 
-	blob, err := blobio.GetBlob(n.store, *key)
-	if err != nil {
-		return err
+	entry, ok := n.cache.Get(*key)
+	if !ok {
+		blob, err := blobio.GetBlob(n.store, *key)
+		if err != nil {
+			return err
+		}
+		if blob.V == nil {
+			return httpx.Error(nil, http.StatusNoContent)
+		}
+		meta, err := blobio.GetBlobMeta(n.store, *key)
+		if err != nil {
+			return err
+		}
+		entry = &blobcache.Entry{Blob: blob.V, Meta: meta}
+		n.cache.Add(entry)
 	}
-	if blob.V == nil {
-		return httpx.Error(nil, http.StatusNoContent)
+	if entry.Meta != nil {
+		data, err := entry.Meta.Encode()
+		if err != nil {
+			return err
+		}
+		w.Header().Set(blobMetaHeaderKey, string(data))
 	}
 
 	w.Header().Set("Content-Type", httpx.OctetStreamContentType)
-	w.Write(blob.V.Data())
+	http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(entry.Blob.Data()))
 	return nil
 }
 
 func (n *Node) handlePutBlob(w http.ResponseWriter, req *http.Request) error {
+	if err := n.admitWrite(); err != nil {
+		return err
+	}
 	if req.ContentLength > blob.DataLenHardLimit {
 		return httpx.Error(errors.New("content length exceeds limit"), http.StatusNotAcceptable)
 	}
-	if req.ContentLength < 0 {
-		return httpx.Error(errors.New("content length unknown"), http.StatusNotAcceptable)
-	}
-	data := make([]byte, req.ContentLength)
-	if _, err := io.ReadFull(req.Body, data); err != nil {
+	// content length is unknown for chunked transfer encoding
+	data, err := blob.Read(req.Body)
+	if err != nil {
+		if err == blob.ErrDataTooLarge {
+			return httpx.Error(err, http.StatusNotAcceptable)
+		}
 		return err
 	}
 
-	blob := blob.New(data)
-	if err := blobio.PutBlob(n.store, blob); err != nil {
+	batch := n.store.NewBatch()
+	if err := blobio.PutBlob(batch, data); err != nil {
+		return err
+	}
+	if v := req.Header.Get(blobMetaHeaderKey); v != "" {
+		meta, err := blobio.DecodeMeta([]byte(v))
+		if err != nil {
+			return httpx.Error(err, http.StatusBadRequest)
+		}
+		if err := blobio.PutBlobMeta(batch, data.Key(), meta); err != nil {
+			return err
+		}
+	}
+	// uploading again rescues the blob from being collected
+	if err := blobio.UnmarkBlob(batch, data.Key(), blobio.GCCandidateMark); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
 		return err
 	}
+	// meta may be replaced
+	n.cache.Remove(data.Key())
+	n.addUsage(len(data.Data()))
 
 	return httpx.ResponseJSON(w, &PutBlobResponse{
-		Key: blob.Key(),
+		Key: data.Key(),
 	})
 }
 
+func (n *Node) handleHasBlob(w http.ResponseWriter, req *http.Request) error {
+	key, err := blob.ParseHexKey(mux.Vars(req)["key"])
+	if err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+
+	has, err := blobio.HasBlob(n.store, *key)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return httpx.Error(nil, http.StatusNoContent)
+	}
+	return nil
+}
+
 func (n *Node) handleGetBlobSlice(w http.ResponseWriter, req *http.Request) error {
 	vars := mux.Vars(req)
 	prefix := vars["prefix"]
 
-	blobIter, err := blobio.NewBlobIterator(n.store, prefix)
+	// stream a point-in-time state of the slice
+	snap, err := n.store.NewSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+
+	blobIter, err := blobio.NewBlobIterator(snap, prefix)
 	if err != nil {
 		return httpx.Error(err, http.StatusBadRequest)
 	}
@@ -238,3 +430,270 @@ func (n *Node) handleGetBlobSlice(w http.ResponseWriter, req *http.Request) erro
 
 	return nil
 }
+
+func (n *Node) handleBatchGetBlobs(w http.ResponseWriter, req *http.Request) error {
+	var reqBody BatchGetRequest
+	if err := json.NewDecoder(req.Body).Decode(&reqBody); err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+	if len(reqBody.Keys) > MaxBatchKeys {
+		return httpx.Error(errors.New("too many keys"), http.StatusBadRequest)
+	}
+
+	w.Header().Set("Content-Type", httpx.OctetStreamContentType)
+	for _, key := range reqBody.Keys {
+		item := blobio.StreamItem{Key: key}
+		if blob, err := blobio.GetBlob(n.store, key); err != nil {
+			log.Warnf("batch get blob %s: %v", key.ToHex(), err)
+			item.Unavailable = true
+		} else {
+			item.Blob = blob.V
+		}
+		if err := blobio.WriteStreamItem(w, &item); err != nil {
+			log.Error(err)
+			return nil
+		}
+	}
+	if err := blobio.EndWriteBlob(w); err != nil {
+		log.Error(err)
+	}
+	return nil
+}
+
+func (n *Node) handleBatchPutBlobs(w http.ResponseWriter, req *http.Request) error {
+	if err := n.admitWrite(); err != nil {
+		return err
+	}
+	var (
+		resp BatchPutResponse
+		size int
+	)
+	batch := n.store.NewBatch()
+	for {
+		item, err := blobio.ReadStreamItem(req.Body)
+		if err != nil {
+			return httpx.Error(err, http.StatusBadRequest)
+		}
+		if item == nil {
+			break
+		}
+		if item.Blob == nil {
+			return httpx.Error(errors.New("absent blob"), http.StatusBadRequest)
+		}
+		if len(resp.Results) >= MaxBatchKeys {
+			return httpx.Error(errors.New("too many blobs"), http.StatusBadRequest)
+		}
+		if err := blobio.PutBlob(batch, item.Blob); err != nil {
+			return err
+		}
+		if err := blobio.UnmarkBlob(batch, item.Key, blobio.GCCandidateMark); err != nil {
+			return err
+		}
+		size += len(item.Blob.Data())
+		resp.Results = append(resp.Results, BatchPutResult{
+			Key:    item.Key,
+			Status: BatchPutOK,
+		})
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	n.addUsage(size)
+	return httpx.ResponseJSON(w, &resp)
+}
+
+func (n *Node) handlePinAction(w http.ResponseWriter, req *http.Request, pin bool) error {
+	vars := mux.Vars(req)
+	key, err := blob.ParseHexKey(vars["key"])
+	if err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+	if pin {
+		err = n.PinBlob(req.Context(), *key, vars["label"])
+	} else {
+		err = n.UnpinBlob(*key, vars["label"])
+	}
+	if err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+	return nil
+}
+
+func (n *Node) handlePinBlob(w http.ResponseWriter, req *http.Request) error {
+	return n.handlePinAction(w, req, true)
+}
+
+func (n *Node) handleUnpinBlob(w http.ResponseWriter, req *http.Request) error {
+	return n.handlePinAction(w, req, false)
+}
+
+func (n *Node) handleGetMetaSlice(w http.ResponseWriter, req *http.Request) error {
+	prefix := mux.Vars(req)["prefix"]
+
+	snap, err := n.store.NewSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+
+	iter, err := blobio.NewMetaIterator(snap, prefix)
+	if err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+	defer iter.Release()
+
+	metas := []blobio.KeyedMeta{}
+	for iter.Next() {
+		meta, err := iter.Meta()
+		if err != nil {
+			return err
+		}
+		metas = append(metas, *meta)
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return httpx.ResponseJSON(w, metas)
+}
+
+func (n *Node) handleGetPinSlice(w http.ResponseWriter, req *http.Request) error {
+	prefix := mux.Vars(req)["prefix"]
+
+	snap, err := n.store.NewSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+
+	iter, err := blobio.NewPinIterator(snap, prefix)
+	if err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+	defer iter.Release()
+
+	pins := []blobio.Pin{}
+	for iter.Next() {
+		pin, err := iter.Pin()
+		if err != nil {
+			return err
+		}
+		pins = append(pins, *pin)
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return httpx.ResponseJSON(w, pins)
+}
+
+func (n *Node) handleGCReached(w http.ResponseWriter, req *http.Request) error {
+	var reqBody GCReachedRequest
+	if err := json.NewDecoder(req.Body).Decode(&reqBody); err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+	return n.MarkReached(reqBody.Epoch, reqBody.Keys)
+}
+
+func (n *Node) handleGCAction(w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+	epoch, err := strconv.ParseInt(vars["epoch"], 10, 64)
+	if err != nil {
+		return httpx.Error(err, http.StatusBadRequest)
+	}
+	action := vars["action"]
+
+	// sweep deletes blobs, so it should be approved by admins
+	var (
+		approval func([]byte) (*ApprovalSubject, error)
+		sweepReq GCSweepRequest
+	)
+	if action == "sweep" {
+		approval = func(data []byte) (*ApprovalSubject, error) {
+			if err := json.Unmarshal(data, &sweepReq); err != nil {
+				return nil, err
+			}
+			approved, err := n.specMgr.GetByTag(specmgr.TagApproved)
+			if err != nil {
+				return nil, err
+			}
+			if approved.V == nil {
+				return nil, errors.New("no approved spec")
+			}
+			return &ApprovalSubject{
+				Action:      ActionGC,
+				Revision:    approved.V.Revision,
+				ContentHash: sweepReq.Hash().ToHex(),
+			}, nil
+		}
+	}
+	signerID := n.MasterID()
+	if signerID == "" {
+		return httpx.Error(errors.New("not invited"), http.StatusForbidden)
+	}
+	if _, err := n.handleSignedRequest(req, &signerID, approval); err != nil {
+		return httpx.Error(err, http.StatusUnauthorized)
+	}
+	if action == "sweep" {
+		if err := n.useGCApproval(&sweepReq, epoch); err != nil {
+			return httpx.Error(err, http.StatusUnauthorized)
+		}
+	}
+
+	switch action {
+	case "mark":
+		resp, err := n.MarkGC(req.Context(), epoch)
+		if err != nil {
+			return err
+		}
+		return httpx.ResponseJSON(w, resp)
+	case "sweep":
+		resp, err := n.SweepGC(req.Context(), epoch, sweepReq.Grace)
+		if err != nil {
+			return err
+		}
+		return httpx.ResponseJSON(w, resp)
+	default:
+		return httpx.Error(errors.New("unknown action"), http.StatusBadRequest)
+	}
+}
+
+// isLoopback returns whether the request comes from loopback address
+func isLoopback(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (n *Node) handleBackup(w http.ResponseWriter, req *http.Request) error {
+	// the archive contains node key, so it's only served on the same host, to who can read the token
+	if !isLoopback(req) {
+		return httpx.Error(errors.New("backup is only allowed from loopback"), http.StatusForbidden)
+	}
+	token := n.options.BackupToken
+	if token == "" {
+		return httpx.Error(errors.New("backup disabled"), http.StatusForbidden)
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Header.Get(backupTokenHeaderKey)), []byte(token)) != 1 {
+		return httpx.Error(errors.New("invalid backup token"), http.StatusUnauthorized)
+	}
+	snap, err := n.store.NewSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+
+	w.Header().Set("Content-Type", httpx.OctetStreamContentType)
+	result, err := backup.Write(w, snap)
+	if err != nil {
+		// archive truncated, which is detected on restore
+		log.Errorf("backup: %v", err)
+		return nil
+	}
+	if result.CorruptCount > 0 {
+		log.Warnf("backup: %d corrupt blobs archived as quarantined", result.CorruptCount)
+	}
+	log.Infof("backup: %d pairs, %d blobs archived", result.PairCount, result.BlobCount)
+	return nil
+}
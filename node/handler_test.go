@@ -0,0 +1,69 @@
+package node_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/backup"
+	"github.com/vechain/solidb/crypto"
+	"github.com/vechain/solidb/kv"
+	. "github.com/vechain/solidb/node"
+)
+
+// testServer invited node served over loopback
+type testServer struct {
+	node   *Node
+	store  kv.Store
+	master *crypto.Identity
+	server *httptest.Server
+}
+
+func newTestServer(options Options) *testServer {
+	n, store := newTestNode(options)
+	master, err := crypto.GenerateIdentity()
+	if err != nil {
+		panic(err)
+	}
+	if err := n.Invite(master.ID(), nil, nil, nil); err != nil {
+		panic(err)
+	}
+	return &testServer{n, store, master, httptest.NewServer(NewHTTPHandler(n))}
+}
+
+func (s *testServer) Close() {
+	s.server.Close()
+	s.store.Close()
+}
+
+// rpc returns RPC to the node, with requests signed by identity if not nil
+func (s *testServer) rpc(identity *crypto.Identity) *RPC {
+	rpc := NewRPC().WithAddr(s.server.Listener.Addr().String())
+	if identity != nil {
+		rpc = rpc.WithIdentity(identity, s.node.ID())
+	}
+	return rpc
+}
+
+func TestBackupAuth(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServer(Options{BackupToken: "token"})
+	defer s.Close()
+
+	for _, token := range []string{"", "other"} {
+		_, err := s.rpc(nil).Backup(token)
+		assert.NotNil(err)
+	}
+
+	body, err := s.rpc(nil).Backup("token")
+	if assert.Nil(err) {
+		defer body.Close()
+		_, err := backup.Verify(body)
+		assert.Nil(err)
+	}
+
+	disabled := newTestServer(Options{})
+	defer disabled.Close()
+	_, err = disabled.rpc(nil).Backup("")
+	assert.NotNil(err)
+}
@@ -31,21 +31,22 @@ func (n *Node) healFaults(ctx context.Context) error {
 			log.Warnf("heal faults: %v", err)
 			continue
 		}
-		blob, err := blobio.GetBlob(n.store, *blobKey)
+		entries, err := n.locateBlob(*blobKey)
+		if err != nil {
+			return err
+		}
+		// the blob may be not stored locally
+		blob, meta, err := n.fetchBlob(ctx, *blobKey, entries)
 		if err != nil {
 			log.Warnf("heal faults: %v", err)
 			continue
 		}
-		if blob.V == nil {
-			log.Warn("heal faults: blob not found locally")
+		if blob == nil {
+			log.Warn("heal faults: blob not found")
 			continue
 		}
-		entries, err := n.locateBlob(*blobKey)
-		if err != nil {
-			return err
-		}
 
-		if err := n.broadcastBlob(ctx, blob.V, entries); err != nil {
+		if err := n.broadcastBlob(ctx, blob, meta, entries); err != nil {
 			return err
 		}
 		if err := blobio.UnmarkBlob(n.store, *blobKey, blobio.FaultBlobMark); err != nil {
@@ -86,16 +87,14 @@ func (n *Node) locateBlob(blobKey blob.Key) ([]spec.Entry, error) {
 }
 
 // broadcastBlob put blob to nodes it belongs to
-func (n *Node) broadcastBlob(ctx context.Context, blob *blob.Blob, entries []spec.Entry) error {
+func (n *Node) broadcastBlob(ctx context.Context, blob *blob.Blob, meta *blobio.Meta, entries []spec.Entry) error {
 	// TODO concurrent
-	rpc := NewRPC().WithContext(ctx)
 	for _, entry := range entries {
 		if entry.ID == n.ID() {
 			// skip local node
 			continue
 		}
-		rpc := rpc.WithAddr(entry.Addr)
-		if err := rpc.PutBlob(blob); err != nil {
+		if err := n.peerRPC(ctx, entry).PutBlob(blob, meta); err != nil {
 			return err
 		}
 	}
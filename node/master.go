@@ -0,0 +1,221 @@
+package node
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/crypto"
+	"github.com/vechain/solidb/kv"
+)
+
+var (
+	masterIDKey = kv.NewTable("master-id", ".master-id").Key(nil)
+	// rotationTable audit chain of master key rotations, keyed by seq
+	rotationTable = kv.NewTable("master-rotation", ".master-rotations/")
+)
+
+// KeyRotation record of master key rotation.
+// It's signed by both old and new master keys, and chained by hash of the previous record,
+// so that the whole chain from cluster ID to current master can be verified.
+type KeyRotation struct {
+	ClusterID string `json:"clusterID"`
+	Seq       int    `json:"seq"`
+	// PrevHash hex of hash of previous record, empty for the first
+	PrevHash string `json:"prevHash"`
+	OldID    string `json:"oldID"`
+	NewID    string `json:"newID"`
+	// Time unix time the rotation created
+	Time int64 `json:"time"`
+	// Signature hex of signature by old key
+	Signature string `json:"signature"`
+	// Proof hex of signature by new key, to prove possession of it
+	Proof string `json:"proof"`
+}
+
+// SigningHash hash of fields signed by old and new keys
+func (r *KeyRotation) SigningHash() crypto.Hash {
+	return crypto.HashSum([]byte(fmt.Sprintf("solidb key rotation\n%s\n%d\n%s\n%s\n%s\n%d",
+		r.ClusterID, r.Seq, r.PrevHash, r.OldID, r.NewID, r.Time)))
+}
+
+// Hash hash of the whole record, referred by the next record
+func (r *KeyRotation) Hash() crypto.Hash {
+	data, _ := json.Marshal(r)
+	return crypto.HashSum(data)
+}
+
+// Sign fill signature and proof by old and new identities
+func (r *KeyRotation) Sign(oldIdentity, newIdentity *crypto.Identity) error {
+	hash := r.SigningHash()
+	sig, err := oldIdentity.Sign(hash)
+	if err != nil {
+		return err
+	}
+	proof, err := newIdentity.Sign(hash)
+	if err != nil {
+		return err
+	}
+	r.Signature, r.Proof = hex.EncodeToString(sig), hex.EncodeToString(proof)
+	return nil
+}
+
+// verify checks the record follows prev, which is nil for the first record
+func (r *KeyRotation) verify(clusterID string, prev *KeyRotation) error {
+	if r.ClusterID != clusterID {
+		return errors.New("cluster ID mismatch")
+	}
+	oldID, seq, prevHash := clusterID, 0, ""
+	if prev != nil {
+		h := prev.Hash()
+		oldID, seq, prevHash = prev.NewID, prev.Seq+1, h.ToHex()
+	}
+	if r.OldID != oldID || r.Seq != seq || r.PrevHash != prevHash {
+		return errors.New("broken rotation chain")
+	}
+	if r.NewID == r.OldID {
+		return errors.New("key not changed")
+	}
+	hash := r.SigningHash()
+	for _, s := range []struct{ sig, id string }{{r.Signature, r.OldID}, {r.Proof, r.NewID}} {
+		sig, err := hex.DecodeString(s.sig)
+		if err != nil {
+			return err
+		}
+		id, err := crypto.RecoverID(hash, sig)
+		if err != nil {
+			return err
+		}
+		if id != s.id {
+			return errors.New("rotation signed by wrong key")
+		}
+	}
+	return nil
+}
+
+// verifyRotations verify the chain of rotations, and returns ID of current master
+func verifyRotations(clusterID string, rotations []KeyRotation) (string, error) {
+	return verifyRotationsFrom(clusterID, nil, rotations)
+}
+
+// verifyRotationsFrom verify the chain of rotations following prev, which is nil for the chain from cluster ID,
+// and returns ID of current master
+func verifyRotationsFrom(clusterID string, prev *KeyRotation, rotations []KeyRotation) (string, error) {
+	masterID := clusterID
+	if prev != nil {
+		masterID = prev.NewID
+	}
+	for i := range rotations {
+		if err := rotations[i].verify(clusterID, prev); err != nil {
+			return "", errors.Wrap(err, "verify rotations")
+		}
+		prev = &rotations[i]
+		masterID = prev.NewID
+	}
+	return masterID, nil
+}
+
+func rotationKey(seq int) []byte {
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], uint32(seq))
+	return key[:]
+}
+
+// putRotations write rotations and the resulting master ID
+func putRotations(w kv.Writer, rotations []KeyRotation) error {
+	table := rotationTable.Writer(w)
+	for _, r := range rotations {
+		data, err := json.Marshal(&r)
+		if err != nil {
+			return err
+		}
+		if err := table.Put(rotationKey(r.Seq), data); err != nil {
+			return err
+		}
+	}
+	if len(rotations) > 0 {
+		return w.Put(masterIDKey, []byte(rotations[len(rotations)-1].NewID))
+	}
+	return nil
+}
+
+// MasterID returns ID of current master, which is the cluster ID unless master key rotated.
+func (n *Node) MasterID() string {
+	n.masterMu.RLock()
+	defer n.masterMu.RUnlock()
+	return n.masterID
+}
+
+// Rotations returns the chain of master key rotations
+func (n *Node) Rotations() ([]KeyRotation, error) {
+	iter := rotationTable.View(n.store).NewIterator(nil)
+	defer iter.Release()
+	var rotations []KeyRotation
+	for iter.Next() {
+		var r KeyRotation
+		if err := json.Unmarshal(iter.Value(), &r); err != nil {
+			return nil, err
+		}
+		rotations = append(rotations, r)
+	}
+	return rotations, iter.Error()
+}
+
+// RotateMaster apply rotations not yet recorded, which continue the recorded chain.
+// The chain may start from any recorded rotation, so that a node lagging behind catches up without old keys.
+// Rotations already recorded should match, and applying them again is no-op, so that interrupted rotation
+// can be resumed. The signer should be the current master, or the master after applied.
+func (n *Node) RotateMaster(signerID string, rotations []KeyRotation) error {
+	n.masterMu.Lock()
+	defer n.masterMu.Unlock()
+
+	if n.clusterID == "" {
+		return errors.New("not in cluster")
+	}
+	if len(rotations) == 0 {
+		return errors.New("no rotation")
+	}
+	recorded, err := n.Rotations()
+	if err != nil {
+		return err
+	}
+	var pending []KeyRotation
+	for i, r := range rotations {
+		if r.Seq < 0 || r.Seq > len(recorded) {
+			return errors.New("broken rotation chain")
+		}
+		if r.Seq == len(recorded) {
+			pending = rotations[i:]
+			break
+		}
+		if recorded[r.Seq].Hash() != r.Hash() {
+			return errors.New("conflicting rotation")
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var prev *KeyRotation
+	if len(recorded) > 0 {
+		prev = &recorded[len(recorded)-1]
+	}
+	masterID, err := verifyRotationsFrom(n.clusterID, prev, pending)
+	if err != nil {
+		return err
+	}
+	if signerID != n.masterID && signerID != masterID {
+		return errors.New("not the master")
+	}
+	batch := n.store.NewBatch()
+	if err := putRotations(batch, pending); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	n.masterID = masterID
+	return nil
+}
@@ -0,0 +1,66 @@
+package node_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/crypto"
+	. "github.com/vechain/solidb/node"
+)
+
+func newRotation(clusterID string, prev *KeyRotation, oldIdentity, newIdentity *crypto.Identity) KeyRotation {
+	r := KeyRotation{
+		ClusterID: clusterID,
+		OldID:     oldIdentity.ID(),
+		NewID:     newIdentity.ID(),
+		Time:      time.Now().Unix(),
+	}
+	if prev != nil {
+		h := prev.Hash()
+		r.Seq, r.PrevHash = prev.Seq+1, h.ToHex()
+	}
+	if err := r.Sign(oldIdentity, newIdentity); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func TestRotateMaster(t *testing.T) {
+	assert := assert.New(t)
+	var keys []*crypto.Identity
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateIdentity()
+		keys = append(keys, key)
+	}
+	clusterID := keys[0].ID()
+	r1 := newRotation(clusterID, nil, keys[0], keys[1])
+	r2 := newRotation(clusterID, &r1, keys[1], keys[2])
+
+	invited := func() *Node {
+		n, _ := newTestNode(Options{})
+		if err := n.Invite(clusterID, nil, nil, nil); err != nil {
+			panic(err)
+		}
+		return n
+	}
+
+	n := invited()
+	assert.NotNil(n.RotateMaster(keys[3].ID(), []KeyRotation{r1}), "not the master")
+	assert.NotNil(n.RotateMaster(keys[0].ID(), []KeyRotation{r2}), "broken chain")
+	assert.Nil(n.RotateMaster(keys[0].ID(), []KeyRotation{r1}))
+	assert.Equal(keys[1].ID(), n.MasterID())
+	assert.Nil(n.RotateMaster(keys[0].ID(), []KeyRotation{r1}), "resumed")
+	assert.NotNil(n.RotateMaster(keys[0].ID(), []KeyRotation{newRotation(clusterID, nil, keys[0], keys[3])}), "conflicting")
+
+	// continue from the recorded rotation
+	assert.Nil(n.RotateMaster(keys[1].ID(), []KeyRotation{r1, r2}))
+	assert.Equal(keys[2].ID(), n.MasterID())
+	rotations, _ := n.Rotations()
+	assert.Equal([]KeyRotation{r1, r2}, rotations)
+
+	// lagging node catches up, requested by the latest master
+	lagging := invited()
+	assert.Nil(lagging.RotateMaster(keys[2].ID(), []KeyRotation{r1, r2}))
+	assert.Equal(keys[2].ID(), lagging.MasterID())
+}
@@ -0,0 +1,54 @@
+package node
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/utils/httpx"
+)
+
+const (
+	// blobMetaHeaderKey carries encoded blob meta between broker and nodes
+	blobMetaHeaderKey = "x-solidb-blob-meta"
+	// CustomMetaHeaderPrefix prefix of headers for custom blob meta
+	CustomMetaHeaderPrefix = "X-Solidb-Meta-"
+)
+
+// MetaFromHeader extract blob meta from public http header. Nil returned if nothing set.
+func MetaFromHeader(header http.Header) *blobio.Meta {
+	var meta blobio.Meta
+	switch ct := header.Get("Content-Type"); ct {
+	// default content types of http clients tell nothing about the blob
+	case "", httpx.OctetStreamContentType, "application/x-www-form-urlencoded":
+	default:
+		meta.ContentType = ct
+	}
+	meta.ContentDisposition = header.Get("Content-Disposition")
+	for k, v := range header {
+		if len(v) == 0 || !strings.HasPrefix(k, CustomMetaHeaderPrefix) || len(k) == len(CustomMetaHeaderPrefix) {
+			continue
+		}
+		if meta.Custom == nil {
+			meta.Custom = make(map[string]string)
+		}
+		meta.Custom[strings.ToLower(k[len(CustomMetaHeaderPrefix):])] = v[0]
+	}
+	if meta.IsEmpty() {
+		return nil
+	}
+	return &meta
+}
+
+// SetMetaHeader set blob meta to public http header.
+func SetMetaHeader(header http.Header, meta *blobio.Meta) {
+	if meta.ContentType != "" {
+		header.Set("Content-Type", meta.ContentType)
+	}
+	if meta.ContentDisposition != "" {
+		header.Set("Content-Disposition", meta.ContentDisposition)
+	}
+	for k, v := range meta.Custom {
+		header.Set(CustomMetaHeaderPrefix+k, v)
+	}
+}
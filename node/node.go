@@ -2,11 +2,13 @@ package node
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/vechain/solidb/blobcache"
 	"github.com/vechain/solidb/crypto"
 	"github.com/vechain/solidb/kv"
 	"github.com/vechain/solidb/node/syncstate"
@@ -14,12 +16,13 @@ import (
 	"github.com/vechain/solidb/specmgr"
 )
 
+// standalone keys are registered as tables, to be guarded against overlapping
 var (
-	nodeKeyKey   = []byte(".node-key")
-	clusterIDKey = []byte(".cluster-id")
+	nodeKeyKey   = kv.NewTable("node-key", ".node-key").Key(nil)
+	clusterIDKey = kv.NewTable("cluster-id", ".cluster-id").Key(nil)
 )
 
-func getOrGenerateNodeKey(store kv.Store) (*crypto.Identity, error) {
+func getOrGenerateNodeKey(store kv.Store, keyType crypto.KeyType) (*crypto.Identity, error) {
 	value, err := store.Get(nodeKeyKey)
 	if err != nil {
 		return nil, err
@@ -28,7 +31,7 @@ func getOrGenerateNodeKey(store kv.Store) (*crypto.Identity, error) {
 		return crypto.NewIdentity(value.V)
 	}
 
-	identity, err := crypto.GenerateIdentity()
+	identity, err := crypto.GenerateIdentityOfType(keyType)
 	if err != nil {
 		return nil, err
 	}
@@ -39,22 +42,60 @@ func getOrGenerateNodeKey(store kv.Store) (*crypto.Identity, error) {
 	return identity, nil
 }
 
+// Options options of node
+type Options struct {
+	// ScrubRate max bytes per second read by background scrubbing, 0 to disable
+	ScrubRate int
+	// CacheSize max bytes of blobs cached in memory, 0 to disable
+	CacheSize int
+	// StoreDir dir of store, to measure usage
+	StoreDir string
+	// Capacity max bytes of store, 0 for unlimited
+	Capacity int64
+	// HighWatermark ratio of capacity, beyond which writes are rejected
+	HighWatermark float64
+	// KeyType type of node key when generated, P-256 if empty
+	KeyType crypto.KeyType
+	// BackupToken secret authorizing backup from loopback, backup disabled if empty
+	BackupToken string
+}
+
 // Node defines local node of solidb.
 type Node struct {
 	store              kv.Store
 	identity           *crypto.Identity
 	clusterID          string
+	masterID           string
+	masterMu           sync.RWMutex
+	adminsMu           sync.RWMutex
 	specMgr            *specmgr.SpecManager
 	syncRequest        chan int
 	lastSyncRequestRev int
+	compactRequest     chan struct{}
+	options            Options
+	cache              *blobcache.Cache
+	nonceMu            sync.Mutex
+	noncePrunedAt      time.Time
+	nonces             nonceCache
+	peersMu            sync.RWMutex
+	peers              map[string]bool
+	// usedBytes size of store, accessed atomically
+	usedBytes int64
+
+	scrubMu     sync.Mutex
+	scrubStatus ScrubStatus
 
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 }
 
 // New creates node instance
-func New(store kv.Store, specMgr *specmgr.SpecManager) (*Node, error) {
-	identity, err := getOrGenerateNodeKey(store)
+func New(store kv.Store, specMgr *specmgr.SpecManager, options Options) (*Node, error) {
+	keyType := options.KeyType
+	if keyType == "" {
+		keyType = crypto.KeyTypeP256
+	}
+	identity, err := getOrGenerateNodeKey(store, keyType)
 	if err != nil {
 		return nil, err
 	}
@@ -62,15 +103,35 @@ func New(store kv.Store, specMgr *specmgr.SpecManager) (*Node, error) {
 	if err != nil {
 		return nil, err
 	}
+	masterIDData, err := store.Get(masterIDKey)
+	if err != nil {
+		return nil, err
+	}
+	masterID := string(clusterIDData.V)
+	if masterIDData.V != nil {
+		masterID = string(masterIDData.V)
+	}
 
-	return &Node{
+	n := &Node{
 		store:     store,
 		identity:  identity,
 		clusterID: string(clusterIDData.V),
-
-		specMgr:     specMgr,
-		syncRequest: make(chan int),
-	}, nil
+		masterID:  masterID,
+
+		specMgr:        specMgr,
+		syncRequest:    make(chan int),
+		compactRequest: make(chan struct{}, 1),
+		options:        options,
+		cache:          blobcache.New(options.CacheSize),
+		scrubStatus:    ScrubStatus{Rate: options.ScrubRate},
+		nonces:         nonceCache{capacity: maxCachedNonces},
+	}
+	if n.capacityEnabled() {
+		if err := n.measureUsage(); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
 }
 
 // Start start running node
@@ -80,6 +141,18 @@ func (n *Node) Start() {
 	n.wg.Add(2)
 	go n.syncSlicesLoop(ctx)
 	go n.healFaultsLoop(ctx)
+	if compacter, ok := n.store.(kv.Compacter); ok {
+		n.wg.Add(1)
+		go n.compactLoop(ctx, compacter)
+	}
+	if n.options.ScrubRate > 0 {
+		n.wg.Add(1)
+		go n.scrubLoop(ctx)
+	}
+	if n.capacityEnabled() {
+		n.wg.Add(1)
+		go n.usageLoop(ctx)
+	}
 }
 
 // Shutdown terminate running node and block until stopped.
@@ -159,6 +232,37 @@ func (n *Node) healFaultsLoop(ctx context.Context) {
 	}
 }
 
+// compactLoop compact store on request
+func (n *Node) compactLoop(ctx context.Context, compacter kv.Compacter) {
+	log.Info("enter compact loop")
+	defer func() {
+		if err := recover(); err != nil {
+			log.Warnln("compact loop recovered:", err)
+		}
+		n.wg.Done()
+		log.Info("leave compact loop")
+	}()
+
+	for {
+		select {
+		case <-n.compactRequest:
+			if err := compacter.Compact(ctx); err != nil {
+				log.Errorf("compact: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// requestCompact request to compact store without blocking
+func (n *Node) requestCompact() {
+	select {
+	case n.compactRequest <- struct{}{}:
+	default:
+	}
+}
+
 // ID returns ID of node.
 func (n *Node) ID() string {
 	return n.identity.ID()
@@ -170,11 +274,29 @@ func (n *Node) ClusterID() string {
 }
 
 // Invite invite the node to join a cluster.
-func (n *Node) Invite(clusterID string, initSpec *spec.Spec) error {
+// The cluster ID is the ID of the first master, and rotations lead to the signer, which should be current master.
+func (n *Node) Invite(signerID string, initSpec *spec.Spec, rotations []KeyRotation, admins *AdminSet) error {
+	clusterID := signerID
+	if len(rotations) > 0 {
+		clusterID = rotations[0].OldID
+	}
+	masterID, err := verifyRotations(clusterID, rotations)
+	if err != nil {
+		return err
+	}
+	if masterID != signerID {
+		return errors.New("not the master")
+	}
+
+	n.masterMu.Lock()
+	defer n.masterMu.Unlock()
 	if n.clusterID != "" {
 		if n.clusterID != clusterID {
 			return errors.New("already in cluster")
 		}
+		if n.masterID != masterID {
+			return errors.New("not the master")
+		}
 		// allow re-enter
 		approved, err := n.specMgr.GetByTag(specmgr.TagApproved)
 		if err != nil {
@@ -188,18 +310,41 @@ func (n *Node) Invite(clusterID string, initSpec *spec.Spec) error {
 	}
 
 	if initSpec != nil {
-		if err := n.specMgr.Commit(*initSpec); err != nil {
+		if err := n.commitSpec(*initSpec); err != nil {
 			return err
 		}
-		if err := n.specMgr.Tag(initSpec.Revision, specmgr.TagApproved); err != nil {
+		if err := n.tagSpec(initSpec.Revision, specmgr.TagApproved); err != nil {
 			return err
 		}
 	}
 
-	if err := n.store.Put(clusterIDKey, []byte(clusterID)); err != nil {
+	batch := n.store.NewBatch()
+	batch.Put(clusterIDKey, []byte(clusterID))
+	if admins != nil {
+		// admins in effect are never replaced by invitation
+		current, err := n.Admins()
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			if err := admins.Validate(); err != nil {
+				return err
+			}
+			data, err := json.Marshal(admins)
+			if err != nil {
+				return err
+			}
+			batch.Put(adminsKey, data)
+		}
+	}
+	if err := putRotations(batch, rotations); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
 		return err
 	}
 	n.clusterID = clusterID
+	n.masterID = masterID
 	return nil
 }
 
@@ -224,16 +369,34 @@ func (n *Node) GetStatus() (*StatusResponse, error) {
 	} else if approved.V != nil {
 		approvedRev = approved.V.Revision
 	}
+	admins, err := n.Admins()
+	if err != nil {
+		return nil, err
+	}
 
-	return &StatusResponse{
+	status := &StatusResponse{
 		NodeID:    n.ID(),
 		ClusterID: n.ClusterID(),
+		MasterID:  n.MasterID(),
+		Admins:    admins,
 		SpecRevisions: Revisions{
 			Newest:   newestRev,
 			Synced:   syncedRev,
 			Approved: approvedRev,
 		},
-	}, nil
+	}
+	if n.options.ScrubRate > 0 {
+		n.scrubMu.Lock()
+		scrub := n.scrubStatus
+		n.scrubMu.Unlock()
+		status.Scrub = &scrub
+	}
+	if n.cache != nil {
+		stats := n.cache.Stats()
+		status.Cache = &stats
+	}
+	status.Storage = n.storageStatus()
+	return status, nil
 }
 
 // GetSyncStatus returns progress of slice syncing
@@ -268,7 +431,7 @@ func (n *Node) ProposeSpec(s spec.Spec) error {
 		}
 	}
 
-	if err := n.specMgr.Commit(s); err != nil {
+	if err := n.commitSpec(s); err != nil {
 		return err
 	}
 
@@ -284,10 +447,10 @@ func (n *Node) ProposeSpec(s spec.Spec) error {
 		if err := syncstate.SetSlicesSynced(n.store, false, entry.V.Slices...); err != nil {
 			return err
 		}
-		if err := n.specMgr.Tag(s.Revision, specmgr.TagSynced); err != nil {
+		if err := n.tagSpec(s.Revision, specmgr.TagSynced); err != nil {
 			return err
 		}
-		if err := n.specMgr.Tag(0, specmgr.TagApproved); err != nil {
+		if err := n.tagSpec(0, specmgr.TagApproved); err != nil {
 			return err
 		}
 	}
@@ -341,7 +504,7 @@ func (n *Node) ApproveSpec(revision int) error {
 		return errors.New("spec not synced")
 	}
 
-	if err := n.specMgr.Tag(revision, specmgr.TagApproved); err != nil {
+	if err := n.tagSpec(revision, specmgr.TagApproved); err != nil {
 		return err
 	}
 	entry, err := n.satEntry(revision)
@@ -0,0 +1,47 @@
+package node_test
+
+import (
+	"fmt"
+
+	"github.com/vechain/solidb/kv"
+	. "github.com/vechain/solidb/node"
+	"github.com/vechain/solidb/spec"
+	"github.com/vechain/solidb/specmgr"
+)
+
+// newTestNode create node on mem store, which holds all slices in spec rev0
+func newTestNode(options Options) (*Node, kv.Store) {
+	store, err := kv.NewMemStore(kv.Options{})
+	if err != nil {
+		panic(err)
+	}
+	return newTestNodeOnStore(store, options), store
+}
+
+func newTestNodeOnStore(store kv.Store, options Options) *Node {
+	n, err := New(store, specmgr.New(store), options)
+	if err != nil {
+		panic(err)
+	}
+	if err := n.ProposeSpec(testSpec(0, n.ID())); err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// testSpec returns spec in which each node holds all slices
+func testSpec(revision int, ids ...string) spec.Spec {
+	var slices []string
+	for i := 0; i < 16; i++ {
+		slices = append(slices, fmt.Sprintf("%x", i))
+	}
+	s := spec.Spec{Revision: revision}
+	for i, id := range ids {
+		s.SAT.Entries = append(s.SAT.Entries, spec.Entry{
+			ID:     id,
+			Addr:   fmt.Sprintf("127.0.0.1:%d", 1+i),
+			Slices: slices,
+		})
+	}
+	return s
+}
@@ -0,0 +1,189 @@
+package node
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vechain/solidb/crypto"
+	"github.com/vechain/solidb/kv"
+	"github.com/vechain/solidb/utils/httpx"
+)
+
+const (
+	timestampHeaderKey = "x-solidb-timestamp"
+	nonceHeaderKey     = "x-solidb-nonce"
+
+	// signatureWindow max clock skew between signer and node.
+	// Signed requests outside the window are rejected, so nonces older than it can be forgotten.
+	signatureWindow = 5 * time.Minute
+	nonceLen        = 16
+)
+
+// signedParams timestamp and nonce of a signed request
+type signedParams struct {
+	timestamp int64
+	nonce     []byte
+}
+
+// newSignedParams returns params with current time and a random nonce
+func newSignedParams() (*signedParams, error) {
+	nonce := make([]byte, nonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &signedParams{time.Now().Unix(), nonce}, nil
+}
+
+func parseSignedParams(header http.Header) (*signedParams, error) {
+	ts, err := strconv.ParseInt(header.Get(timestampHeaderKey), 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid timestamp")
+	}
+	nonce, err := hex.DecodeString(header.Get(nonceHeaderKey))
+	if err != nil || len(nonce) != nonceLen {
+		return nil, errors.New("invalid nonce")
+	}
+	return &signedParams{ts, nonce}, nil
+}
+
+// checkFresh returns error if timestamp is out of window
+func (p *signedParams) checkFresh(now time.Time) error {
+	skew := now.Sub(time.Unix(p.timestamp, 0))
+	if skew > signatureWindow || skew < -signatureWindow {
+		return errors.New("stale request")
+	}
+	return nil
+}
+
+// signingHash hash of material signed for a request, cluster ID is empty for requests of the master
+func signingHash(clusterID, targetID, method, requestURI string, p *signedParams, body []byte) crypto.Hash {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n%s\n%s %s\n%d\n%x\n", clusterID, targetID, method, requestURI, p.timestamp, p.nonce)
+	buf.Write(body)
+	return crypto.HashSum(buf.Bytes())
+}
+
+// signRequest set signature headers of request with body
+func signRequest(req *http.Request, signer Signer, targetID string, p *signedParams, body []byte) error {
+	hash := signingHash(signer.ClusterID(), targetID, req.Method, req.URL.RequestURI(), p, body)
+	sig, err := signer.Identity().Sign(hash)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(signatureHeaderKey, hex.EncodeToString(sig))
+	req.Header.Set(targetIDHeaderKey, targetID)
+	req.Header.Set(timestampHeaderKey, strconv.FormatInt(p.timestamp, 10))
+	req.Header.Set(nonceHeaderKey, hex.EncodeToString(p.nonce))
+	if clusterID := signer.ClusterID(); clusterID != "" {
+		req.Header.Set(clusterIDHeaderKey, clusterID)
+	}
+	return nil
+}
+
+// nonceTable records nonces of master signed requests, keyed by timestamp | signer | nonce,
+// so that expired ones are pruned by range.
+var nonceTable = kv.NewTable("nonce", ".nonces/")
+
+func nonceKey(timestamp int64, signerID string, nonce []byte) []byte {
+	key := make([]byte, 8, 8+len(signerID)+len(nonce))
+	binary.BigEndian.PutUint64(key, uint64(timestamp))
+	return append(append(key, signerID...), nonce...)
+}
+
+// consumeStoredNonce records nonce of the signer in store, and returns error if it's already used.
+// It's for master signed requests, which are rare but critical, so that they can't be replayed
+// even if the node restarts. Nonces out of window are pruned meanwhile.
+func (n *Node) consumeStoredNonce(signerID string, p *signedParams) error {
+	n.nonceMu.Lock()
+	defer n.nonceMu.Unlock()
+
+	table := nonceTable.Store(n.store)
+	key := nonceKey(p.timestamp, signerID, p.nonce)
+	used, err := table.Has(key)
+	if err != nil {
+		return err
+	}
+	if used {
+		return errors.New("replayed request")
+	}
+
+	batch := table.NewBatch()
+	now := time.Now()
+	if now.Sub(n.noncePrunedAt) > signatureWindow {
+		expired := nonceKey(now.Add(-signatureWindow).Unix(), "", nil)
+		iter := table.NewIterator(kv.NewRange(nil, expired))
+		for iter.Next() {
+			batch.Delete(append([]byte(nil), iter.Key()...))
+		}
+		err := iter.Error()
+		iter.Release()
+		if err != nil {
+			return err
+		}
+		n.noncePrunedAt = now
+	}
+	batch.Put(key, nil)
+	return batch.Write()
+}
+
+// maxCachedNonces max count of nonces cached in memory
+const maxCachedNonces = 1 << 20
+
+var errTooManyNonces = httpx.Error(errors.New("too many requests"), http.StatusServiceUnavailable)
+
+// nonceCache nonces of requests between nodes seen within the signature window, keyed by signer | nonce.
+// It's kept in memory, so that a request doesn't cost a store write, and forgotten when the node restarts.
+// Once full, requests are rejected until nonces expire.
+type nonceCache struct {
+	mu       sync.Mutex
+	capacity int
+	seen     map[string]int64
+	prunedAt time.Time
+}
+
+// consume records nonce of the signer, and returns error if it's already used.
+// Nonces out of window are pruned meanwhile.
+func (c *nonceCache) consume(signerID string, p *signedParams, now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := signerID + string(p.nonce)
+	if _, used := c.seen[key]; used {
+		return errors.New("replayed request")
+	}
+	if c.seen == nil {
+		c.seen = make(map[string]int64)
+	}
+	if now.Sub(c.prunedAt) > signatureWindow || len(c.seen) >= c.capacity {
+		c.prune(now)
+	}
+	if len(c.seen) >= c.capacity {
+		return errTooManyNonces
+	}
+	c.seen[key] = p.timestamp
+	return nil
+}
+
+// prune forget nonces whose requests are out of window
+func (c *nonceCache) prune(now time.Time) {
+	expired := now.Add(-signatureWindow).Unix()
+	for k, timestamp := range c.seen {
+		if timestamp < expired {
+			delete(c.seen, k)
+		}
+	}
+	c.prunedAt = now
+}
+
+// consumeNonce records nonce of the signer in memory, and returns error if it's already used.
+func (n *Node) consumeNonce(signerID string, p *signedParams) error {
+	return n.nonces.consume(signerID, p, time.Now())
+}
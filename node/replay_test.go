@@ -0,0 +1,109 @@
+package node_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/crypto"
+	. "github.com/vechain/solidb/node"
+)
+
+// testSigner signs requests along with the cluster ID, which is empty for the master
+type testSigner struct {
+	identity  *crypto.Identity
+	clusterID string
+}
+
+func (s testSigner) Identity() *crypto.Identity { return s.identity }
+func (s testSigner) ClusterID() string          { return s.clusterID }
+
+// newSignedRequest returns request to the node signed at the given time
+func (s *testServer) newSignedRequest(method, path string, signer Signer, body []byte, at time.Time) *http.Request {
+	req, err := http.NewRequest(method, s.server.URL+path, bytes.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	if err := SignRequestAt(req, signer, s.node.ID(), body, at); err != nil {
+		panic(err)
+	}
+	return req
+}
+
+func doStatus(req *http.Request) int {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestReplay(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServer(Options{})
+	defer s.Close()
+	master := testSigner{identity: s.master}
+
+	path := fmt.Sprintf("/node/gc/%d?action=mark", time.Now().UnixNano())
+	req := s.newSignedRequest(http.MethodPost, path, master, nil, time.Now())
+	assert.Equal(http.StatusOK, doStatus(req))
+	assert.Equal(http.StatusUnauthorized, doStatus(req), "replayed")
+
+	req = s.newSignedRequest(http.MethodPost, path, master, nil, time.Now())
+	assert.Equal(http.StatusOK, doStatus(req), "new nonce")
+
+	for _, at := range []time.Time{time.Now().Add(-6 * time.Minute), time.Now().Add(6 * time.Minute)} {
+		req = s.newSignedRequest(http.MethodPost, path, master, nil, at)
+		assert.Equal(http.StatusUnauthorized, doStatus(req), "stale")
+	}
+	req = s.newSignedRequest(http.MethodPost, path, master, nil, time.Now().Add(-4*time.Minute))
+	assert.Equal(http.StatusOK, doStatus(req), "within window")
+
+	// fresh, but not signed by master
+	other, _ := crypto.GenerateIdentity()
+	req = s.newSignedRequest(http.MethodPost, path, testSigner{identity: other}, nil, time.Now())
+	assert.Equal(http.StatusUnauthorized, doStatus(req))
+}
+
+func TestReplayAfterRestart(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServer(Options{})
+	defer s.Close()
+
+	path := fmt.Sprintf("/node/gc/%d?action=mark", time.Now().UnixNano())
+	req := s.newSignedRequest(http.MethodPost, path, testSigner{identity: s.master}, nil, time.Now())
+	assert.Equal(http.StatusOK, doStatus(req))
+
+	// master signed nonces are kept in store
+	s.server.Close()
+	restarted := httptest.NewServer(NewHTTPHandler(newTestNodeOnStore(s.store, Options{})))
+	defer restarted.Close()
+	replayed, _ := http.NewRequest(req.Method, restarted.URL+path, nil)
+	replayed.Header = req.Header
+	assert.Equal(http.StatusUnauthorized, doStatus(replayed))
+
+	fresh, _ := http.NewRequest(http.MethodPost, restarted.URL+path, nil)
+	SignRequestAt(fresh, testSigner{identity: s.master}, s.node.ID(), nil, time.Now())
+	assert.Equal(http.StatusOK, doStatus(fresh))
+}
+
+func TestNonceCapacity(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServer(Options{})
+	defer s.Close()
+	s.node.SetNonceCapacity(1)
+
+	key, _ := crypto.GenerateIdentity()
+	peer := testSigner{key, s.node.ClusterID()}
+	assert.Nil(s.node.ProposeSpec(testSpec(1, s.node.ID(), key.ID())))
+	data := []byte("data")
+	req := s.newSignedRequest(http.MethodPost, "/node/blobs", peer, data, time.Now())
+	assert.Equal(http.StatusOK, doStatus(req))
+	req = s.newSignedRequest(http.MethodPost, "/node/blobs", peer, data, time.Now())
+	assert.Equal(http.StatusServiceUnavailable, doStatus(req), "full")
+}
@@ -3,7 +3,6 @@ package node
 import (
 	"bytes"
 	"context"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io"
@@ -20,11 +19,12 @@ import (
 )
 
 type RPC struct {
-	client   *http.Client
-	baseURL  string
-	ctx      context.Context
-	identity *crypto.Identity
-	targetID string
+	client    *http.Client
+	baseURL   string
+	ctx       context.Context
+	signer    Signer
+	targetID  string
+	approvals *Approvals
 }
 
 var defaultTransport = http.Transport{}
@@ -52,45 +52,80 @@ func (rpc *RPC) WithContext(ctx context.Context) *RPC {
 }
 
 func (rpc *RPC) WithIdentity(identity *crypto.Identity, targetID string) *RPC {
+	return rpc.WithSigner(identitySigner{identity}, targetID)
+}
+
+// WithSigner returns RPC signing requests by signer, along with its cluster ID
+func (rpc *RPC) WithSigner(signer Signer, targetID string) *RPC {
 	cp := *rpc
-	cp.identity = identity
+	cp.signer = signer
 	cp.targetID = targetID
 	return &cp
 }
 
-func (rpc *RPC) doRequest(req *http.Request) (*http.Response, []byte, error) {
-	if rpc.identity != nil {
+// identitySigner signer of bare identity, claiming no cluster
+type identitySigner struct {
+	identity *crypto.Identity
+}
+
+func (s identitySigner) Identity() *crypto.Identity { return s.identity }
+func (s identitySigner) ClusterID() string          { return "" }
+
+// WithApprovals returns RPC sending approvals of admins along with requests
+func (rpc *RPC) WithApprovals(approvals *Approvals) *RPC {
+	cp := *rpc
+	cp.approvals = approvals
+	return &cp
+}
+
+func (rpc *RPC) sendRequest(req *http.Request) (*http.Response, error) {
+	if rpc.signer != nil {
 		var data []byte
 		if req.GetBody != nil {
 			body, err := req.GetBody()
 			if err != nil {
-				return nil, nil, err
+				return nil, err
 			}
 			data, err = ioutil.ReadAll(body)
 			if err != nil {
-				return nil, nil, err
+				return nil, err
 			}
 		}
-		vdata := append([]byte(rpc.targetID+"\n"+req.URL.RequestURI()+"\n"), data...)
+		params, err := newSignedParams()
+		if err != nil {
+			return nil, err
+		}
+		if err := signRequest(req, rpc.signer, rpc.targetID, params, data); err != nil {
+			return nil, err
+		}
+	}
 
-		hash := crypto.HashSum(vdata)
-		sig, err := rpc.identity.Sign(hash)
+	if rpc.approvals != nil {
+		value, err := encodeApprovals(rpc.approvals)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
-		req.Header.Set(signatureHeaderKey, hex.EncodeToString(sig))
-		req.Header.Set(targetIDHeaderKey, rpc.targetID)
+		req.Header.Set(approvalsHeaderKey, value)
 	}
 
 	req = req.WithContext(rpc.ctx)
 	resp, err := rpc.client.Do(req)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	defer resp.Body.Close()
 	if err := httpx.HandleResponseError(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (rpc *RPC) doRequest(req *http.Request) (*http.Response, []byte, error) {
+	resp, err := rpc.sendRequest(req)
+	if err != nil {
 		return nil, nil, err
 	}
+	defer resp.Body.Close()
 	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, nil, err
@@ -98,9 +133,11 @@ func (rpc *RPC) doRequest(req *http.Request) (*http.Response, []byte, error) {
 	return resp, data, nil
 }
 
-func (rpc *RPC) Invite(initSpec *spec.Spec) (nodeID string, err error) {
+func (rpc *RPC) Invite(initSpec *spec.Spec, rotations []KeyRotation, admins *AdminSet) (nodeID string, err error) {
 	data, err := json.Marshal(&InviteRequest{
-		InitSpec: initSpec,
+		InitSpec:  initSpec,
+		Rotations: rotations,
+		Admins:    admins,
 	})
 	if err != nil {
 		return "", err
@@ -131,6 +168,44 @@ func (rpc *RPC) Invite(initSpec *spec.Spec) (nodeID string, err error) {
 	return respBody.NodeID, nil
 }
 
+// SetAdmins request node to replace admin set, approvals of admins in effect should be attached
+func (rpc *RPC) SetAdmins(admins *AdminSet) error {
+	data, err := json.Marshal(&SetAdminsRequest{Admins: *admins})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(
+		http.MethodPut,
+		rpc.baseURL+"admins",
+		bytes.NewReader(data),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", httpx.JSONContentType)
+	_, _, err = rpc.doRequest(req)
+	return err
+}
+
+// RotateMaster request node to apply chain of master key rotations
+func (rpc *RPC) RotateMaster(rotations []KeyRotation) error {
+	data, err := json.Marshal(&RotateMasterRequest{Rotations: rotations})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(
+		http.MethodPost,
+		rpc.baseURL+"master/rotations",
+		bytes.NewReader(data),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", httpx.JSONContentType)
+	_, _, err = rpc.doRequest(req)
+	return err
+}
+
 func (rpc *RPC) GetStatus() (*StatusResponse, error) {
 	req, err := http.NewRequest(
 		http.MethodGet,
@@ -172,30 +247,59 @@ func (rpc *RPC) GetSyncStatus(revision int) (*SyncStatusResponse, error) {
 	return &status, nil
 }
 
+// HasBlob returns whether the node has the blob.
+func (rpc *RPC) HasBlob(blobKey blob.Key) (bool, error) {
+	req, err := http.NewRequest(
+		http.MethodHead,
+		rpc.baseURL+"blobs/"+blobKey.ToHex(),
+		nil,
+	)
+	if err != nil {
+		return false, err
+	}
+	resp, _, err := rpc.doRequest(req)
+	if err != nil {
+		return false, err
+	}
+	return resp.StatusCode != http.StatusNoContent, nil
+}
+
 func (rpc *RPC) GetBlob(blobKey blob.Key) (*blobio.OptBlob, error) {
+	blob, _, err := rpc.GetBlobWithMeta(blobKey)
+	return blob, err
+}
+
+// GetBlobWithMeta get blob along with its meta, which is nil if absent.
+func (rpc *RPC) GetBlobWithMeta(blobKey blob.Key) (*blobio.OptBlob, *blobio.Meta, error) {
 	req, err := http.NewRequest(
 		http.MethodGet,
 		rpc.baseURL+"blobs/"+blobKey.ToHex(),
 		nil,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	resp, data, err := rpc.doRequest(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if resp.StatusCode == http.StatusNoContent {
-		return &blobio.OptBlob{}, nil
+		return &blobio.OptBlob{}, nil, nil
 	}
 	blob := blob.New(data)
 	if blob.Key() != blobKey {
-		return nil, errors.New("get blob with wrong key")
+		return nil, nil, errors.New("get blob with wrong key")
+	}
+	var meta *blobio.Meta
+	if v := resp.Header.Get(blobMetaHeaderKey); v != "" {
+		if meta, err = blobio.DecodeMeta([]byte(v)); err != nil {
+			return nil, nil, err
+		}
 	}
-	return &blobio.OptBlob{blob}, nil
+	return &blobio.OptBlob{V: blob}, meta, nil
 }
 
-func (rpc *RPC) PutBlob(blob *blob.Blob) error {
+func (rpc *RPC) PutBlob(blob *blob.Blob, meta *blobio.Meta) error {
 	req, err := http.NewRequest(
 		http.MethodPost,
 		rpc.baseURL+"blobs",
@@ -205,6 +309,13 @@ func (rpc *RPC) PutBlob(blob *blob.Blob) error {
 		return err
 	}
 	req.Header.Set("Content-Type", httpx.OctetStreamContentType)
+	if meta != nil {
+		data, err := meta.Encode()
+		if err != nil {
+			return err
+		}
+		req.Header.Set(blobMetaHeaderKey, string(data))
+	}
 	_, data, err := rpc.doRequest(req)
 	if err != nil {
 		return err
@@ -219,6 +330,45 @@ func (rpc *RPC) PutBlob(blob *blob.Blob) error {
 	return nil
 }
 
+// BatchPutBlobs put blobs to node, which writes them atomically.
+func (rpc *RPC) BatchPutBlobs(blobs []*blob.Blob) error {
+	var buf bytes.Buffer
+	for _, b := range blobs {
+		if err := blobio.WriteBlob(&buf, b); err != nil {
+			return err
+		}
+	}
+	if err := blobio.EndWriteBlob(&buf); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(
+		http.MethodPost,
+		rpc.baseURL+"blobs:batchPut",
+		bytes.NewReader(buf.Bytes()),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", httpx.OctetStreamContentType)
+	_, data, err := rpc.doRequest(req)
+	if err != nil {
+		return err
+	}
+	var respBody BatchPutResponse
+	if err := json.Unmarshal(data, &respBody); err != nil {
+		return err
+	}
+	if len(respBody.Results) != len(blobs) {
+		return errors.New("batch put returned incorrect results")
+	}
+	for i, r := range respBody.Results {
+		if r.Key != blobs[i].Key() || r.Status != BatchPutOK {
+			return errors.New("batch put returned incorrect results")
+		}
+	}
+	return nil
+}
+
 func (rpc *RPC) GetBlobSlice(prefix string) (io.ReadCloser, error) {
 
 	req, err := http.NewRequest(
@@ -226,13 +376,53 @@ func (rpc *RPC) GetBlobSlice(prefix string) (io.ReadCloser, error) {
 		rpc.baseURL+"blobs?prefix="+url.QueryEscape(prefix),
 		nil,
 	)
-	req = req.WithContext(rpc.ctx)
-	resp, err := rpc.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
-	if err := httpx.HandleResponseError(resp); err != nil {
-		resp.Body.Close()
+	resp, err := rpc.sendRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Backup returns archive of node store, authorized by backup token. It's only allowed from loopback.
+func (rpc *RPC) Backup(token string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(
+		http.MethodGet,
+		rpc.baseURL+"backup",
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(backupTokenHeaderKey, token)
+	resp, err := rpc.sendRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// BatchGetBlobs returns stream of blobs, or marks of absent blobs, in order of keys.
+func (rpc *RPC) BatchGetBlobs(keys []blob.Key) (io.ReadCloser, error) {
+	data, err := json.Marshal(&BatchGetRequest{
+		Keys: keys,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(
+		http.MethodPost,
+		rpc.baseURL+"blobs:batchGet",
+		bytes.NewReader(data),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", httpx.JSONContentType)
+	resp, err := rpc.sendRequest(req)
+	if err != nil {
 		return nil, err
 	}
 	return resp.Body, nil
@@ -275,3 +465,127 @@ func (rpc *RPC) ApproveSpec(revision int) error {
 func (rpc *RPC) SyncToSpec(revision int) error {
 	return rpc.performSpecAction(revision, "sync")
 }
+
+func (rpc *RPC) performPinAction(method string, blobKey blob.Key, label string) error {
+	req, err := http.NewRequest(
+		method,
+		rpc.baseURL+"blobs/"+blobKey.ToHex()+"/pins/"+url.PathEscape(label),
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	if _, _, err := rpc.doRequest(req); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (rpc *RPC) PinBlob(blobKey blob.Key, label string) error {
+	return rpc.performPinAction(http.MethodPut, blobKey, label)
+}
+
+func (rpc *RPC) UnpinBlob(blobKey blob.Key, label string) error {
+	return rpc.performPinAction(http.MethodDelete, blobKey, label)
+}
+
+// GetMetaSlice get metas of blobs in slice
+func (rpc *RPC) GetMetaSlice(prefix string) ([]blobio.KeyedMeta, error) {
+	req, err := http.NewRequest(
+		http.MethodGet,
+		rpc.baseURL+"metas?prefix="+url.QueryEscape(prefix),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, data, err := rpc.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	var metas []blobio.KeyedMeta
+	if err := json.Unmarshal(data, &metas); err != nil {
+		return nil, err
+	}
+	return metas, nil
+}
+
+func (rpc *RPC) GetPinSlice(prefix string) ([]blobio.Pin, error) {
+	req, err := http.NewRequest(
+		http.MethodGet,
+		rpc.baseURL+"pins?prefix="+url.QueryEscape(prefix),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, data, err := rpc.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	var pins []blobio.Pin
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+func (rpc *RPC) MarkReached(epoch int64, keys []blob.Key) error {
+	data, err := json.Marshal(&GCReachedRequest{
+		Epoch: epoch,
+		Keys:  keys,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(
+		http.MethodPost,
+		rpc.baseURL+"gc/reached",
+		bytes.NewReader(data),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", httpx.JSONContentType)
+	if _, _, err := rpc.doRequest(req); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (rpc *RPC) performGCAction(epoch int64, action string, body interface{}, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(
+		http.MethodPost,
+		rpc.baseURL+"gc/"+strconv.FormatInt(epoch, 10)+"?action="+url.QueryEscape(action),
+		bytes.NewReader(data),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", httpx.JSONContentType)
+	_, data, err = rpc.doRequest(req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+func (rpc *RPC) MarkGC(epoch int64) (*GCMarkResponse, error) {
+	var resp GCMarkResponse
+	if err := rpc.performGCAction(epoch, "mark", struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (rpc *RPC) SweepGC(epoch int64, r *GCSweepRequest) (*GCSweepResponse, error) {
+	var resp GCSweepResponse
+	if err := rpc.performGCAction(epoch, "sweep", r, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,237 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/specmgr"
+)
+
+const (
+	scrubBatchLen = 100
+	// min interval between starts of scrub passes
+	scrubPassInterval = time.Hour
+)
+
+// scrubLoop walks all blobs repeatedly, to find and repair corrupt ones
+func (n *Node) scrubLoop(ctx context.Context) {
+	log.Info("enter scrub loop")
+	defer func() {
+		if err := recover(); err != nil {
+			log.Warnln("scrub loop recovered:", err)
+		}
+		n.wg.Done()
+		log.Info("leave scrub loop")
+	}()
+
+	for {
+		start := time.Now()
+		if err := n.scrubPass(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorf("scrub: %v", err)
+		}
+		timer := time.NewTimer(scrubPassInterval - time.Since(start))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (n *Node) updateScrubStatus(f func(s *ScrubStatus)) {
+	n.scrubMu.Lock()
+	defer n.scrubMu.Unlock()
+	f(&n.scrubStatus)
+}
+
+// scrubPass scrub all blobs once, with rate limited
+func (n *Node) scrubPass(ctx context.Context) error {
+	n.updateScrubStatus(func(s *ScrubStatus) {
+		s.Pass++
+		s.Cursor = ""
+		s.Scanned = 0
+	})
+	// retry blobs failed to be repaired in previous passes
+	if err := n.repairQuarantined(ctx); err != nil {
+		return err
+	}
+
+	var cursor *blob.Key
+	for {
+		start := time.Now()
+		keys, corrupt, size, err := n.scrubBatch(cursor)
+		if err != nil {
+			return err
+		}
+		for _, key := range corrupt {
+			if err := n.handleCorrupt(ctx, key); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				log.Warnf("scrub: blob %s: %v", key.ToHex(), err)
+			}
+		}
+		if len(keys) == 0 {
+			break
+		}
+		cursor = &keys[len(keys)-1]
+		n.updateScrubStatus(func(s *ScrubStatus) {
+			s.Cursor = cursor.ToHex()
+			s.Scanned += len(keys)
+		})
+
+		// throttle
+		expected := time.Duration(int64(size) * int64(time.Second) / int64(n.options.ScrubRate))
+		timer := time.NewTimer(expected - time.Since(start))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	now := time.Now()
+	n.updateScrubStatus(func(s *ScrubStatus) {
+		s.LastCompletedAt = &now
+	})
+	return nil
+}
+
+// scrubBatch verify blobs next to cursor. Keys of scrubbed and corrupt blobs, and size of scrubbed data returned.
+// Values are read one by one rather than by the iterator, since a value failed to be read or decrypted
+// makes the iterator error out.
+func (n *Node) scrubBatch(cursor *blob.Key) (keys []blob.Key, corrupt []blob.Key, size int, err error) {
+	iter, err := blobio.NewBlobIterator(n.store, "")
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer iter.Release()
+
+	var ok bool
+	if cursor == nil {
+		ok = iter.Next()
+	} else if ok = iter.Seek(*cursor); ok {
+		key, err := iter.Key()
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if *key == *cursor {
+			ok = iter.Next()
+		}
+	}
+	for ; ok && len(keys) < scrubBatchLen; ok = iter.Next() {
+		key, err := iter.Key()
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		keys = append(keys, *key)
+		data, err := blobio.GetBlob(n.store, *key)
+		if err != nil {
+			corrupt = append(corrupt, *key)
+			continue
+		}
+		if data.V != nil {
+			size += len(data.V.Data())
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, nil, 0, err
+	}
+	return keys, corrupt, size, nil
+}
+
+// handleCorrupt quarantine a corrupt blob and try to repair it
+func (n *Node) handleCorrupt(ctx context.Context, key blob.Key) error {
+	quarantined, err := blobio.QuarantineBlob(n.store, key)
+	if err != nil {
+		return err
+	}
+	if !quarantined {
+		// overwritten since scanned
+		return nil
+	}
+	log.Warnf("scrub: blob %s corrupt, quarantined", key.ToHex())
+	n.updateScrubStatus(func(s *ScrubStatus) {
+		s.Corrupt++
+		s.Quarantined++
+	})
+	return n.repairBlob(ctx, key)
+}
+
+// repairQuarantined try to repair all quarantined blobs
+func (n *Node) repairQuarantined(ctx context.Context) error {
+	var keys []blob.Key
+	iter := blobio.NewMarkIterator(n.store, blobio.CorruptBlobMark)
+	for iter.Next() {
+		key, err := iter.BlobKey()
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		keys = append(keys, *key)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	n.updateScrubStatus(func(s *ScrubStatus) {
+		s.Quarantined = len(keys)
+	})
+	for _, key := range keys {
+		if err := n.repairBlob(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repairBlob fetch a good copy of quarantined blob from replicas in approved spec.
+// The blob stays quarantined if no replica has it.
+func (n *Node) repairBlob(ctx context.Context, key blob.Key) error {
+	approved, err := n.specMgr.GetByTag(specmgr.TagApproved)
+	if err != nil {
+		return err
+	}
+	if approved.V == nil {
+		return errors.New("no approved spec")
+	}
+	data, _, err := n.fetchBlob(ctx, key, approved.V.SAT.Locate(key.ToHex()))
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Warnf("scrub: repair blob %s: %v", key.ToHex(), err)
+		return nil
+	}
+	if data == nil {
+		log.Warnf("scrub: repair blob %s: no good copy found", key.ToHex())
+		return nil
+	}
+
+	batch := n.store.NewBatch()
+	if err := blobio.PutBlob(batch, data); err != nil {
+		return err
+	}
+	if err := blobio.UnmarkBlob(batch, key, blobio.CorruptBlobMark); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Infof("scrub: blob %s repaired", key.ToHex())
+	n.updateScrubStatus(func(s *ScrubStatus) {
+		s.Repaired++
+		s.Quarantined--
+	})
+	return nil
+}
@@ -0,0 +1,124 @@
+package node_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/kv"
+	. "github.com/vechain/solidb/node"
+)
+
+// randomBlobs put blobs of incompressible data, so that data is stored as is
+func randomBlobs(store kv.Store, count int) []*blob.Blob {
+	var blobs []*blob.Blob
+	for i := 0; i < count; i++ {
+		data := make([]byte, 1000)
+		rand.Read(data)
+		b := blob.New(data)
+		blobio.PutBlob(store, b)
+		blobs = append(blobs, b)
+	}
+	return blobs
+}
+
+// scrubOnce run node until the first scrub pass completed, and returns scrub status
+func scrubOnce(n *Node) *ScrubStatus {
+	n.Start()
+	defer n.Shutdown()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		status, err := n.GetStatus()
+		if err != nil {
+			panic(err)
+		}
+		if status.Scrub.LastCompletedAt != nil {
+			return status.Scrub
+		}
+	}
+	panic("scrub pass not completed")
+}
+
+func assertQuarantined(assert *assert.Assertions, store kv.Store, bad *blob.Blob, good []*blob.Blob) {
+	assert.False(hasBlob(store, bad.Key()))
+	mark, err := blobio.GetBlobMark(store, bad.Key(), blobio.CorruptBlobMark)
+	assert.Nil(err)
+	assert.NotNil(mark.V)
+	for _, b := range good {
+		got, err := blobio.GetBlob(store, b.Key())
+		if assert.Nil(err) && assert.NotNil(got.V) {
+			assert.Equal(b.Data(), got.V.Data())
+		}
+	}
+}
+
+func TestScrubSegmentStore(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir(os.TempDir(), "db")
+	defer os.RemoveAll(dir)
+	store, err := kv.NewStore(dir, kv.Options{Engine: kv.EngineSegment, ValueThreshold: 64})
+	assert.Nil(err)
+	defer store.Close()
+
+	blobs := randomBlobs(store, 4)
+	bad := blobs[1]
+	files, _ := filepath.Glob(filepath.Join(dir, "segments", "*"))
+	flipped := false
+	for _, f := range files {
+		content, _ := ioutil.ReadFile(f)
+		if i := bytes.Index(content, bad.Data()[100:132]); i >= 0 {
+			content[i] ^= 1
+			assert.Nil(ioutil.WriteFile(f, content, 0600))
+			flipped = true
+		}
+	}
+	assert.True(flipped)
+
+	status := scrubOnce(newTestNodeOnStore(store, Options{ScrubRate: 1 << 20}))
+	assert.Equal(4, status.Scanned)
+	assert.Equal(1, status.Corrupt)
+	assert.Equal(1, status.Quarantined)
+	assertQuarantined(assert, store, bad, []*blob.Blob{blobs[0], blobs[2], blobs[3]})
+}
+
+func TestScrubEncryptedStore(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir(os.TempDir(), "db")
+	defer os.RemoveAll(dir)
+	options := kv.Options{Encryption: &kv.EncryptionOptions{Key: bytes.Repeat([]byte{1}, 32)}}
+	store, err := kv.NewStore(dir, options)
+	assert.Nil(err)
+	blobs := randomBlobs(store, 4)
+	bad := blobs[1]
+	store.Close()
+
+	// tamper the sealed value
+	db, err := leveldb.OpenFile(dir, nil)
+	assert.Nil(err)
+	blobKey := bad.Key()
+	key := append([]byte("/"), blobKey[:]...)
+	sealed, err := db.Get(key, nil)
+	assert.Nil(err)
+	sealed[len(sealed)-1] ^= 1
+	assert.Nil(db.Put(key, sealed, nil))
+	db.Close()
+
+	store, err = kv.NewStore(dir, options)
+	assert.Nil(err)
+	defer store.Close()
+	_, err = blobio.GetBlob(store, bad.Key())
+	assert.True(kv.IsCorrupt(err))
+
+	status := scrubOnce(newTestNodeOnStore(store, Options{ScrubRate: 1 << 20}))
+	assert.Equal(4, status.Scanned)
+	assert.Equal(1, status.Corrupt)
+	assert.Equal(1, status.Quarantined)
+	assertQuarantined(assert, store, bad, []*blob.Blob{blobs[0], blobs[2], blobs[3]})
+}
@@ -8,6 +8,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/vechain/solidb/blobio"
 	"github.com/vechain/solidb/node/syncstate"
+	"github.com/vechain/solidb/spec"
 	"github.com/vechain/solidb/specmgr"
 )
 
@@ -44,13 +45,23 @@ func (n *Node) syncSlices(ctx context.Context, revision int) error {
 
 			entry := entries[i]
 			log.Infof("syncing slice %s from %s ...", unsyncedSlice, entry.Addr)
-			count, err := n.importRemoteBlobSlice(ctx, entry.Addr, unsyncedSlice)
+			count, err := n.importRemoteBlobSlice(ctx, entry, unsyncedSlice)
 			if err != nil {
 				log.Warnf("sync slice %s from %s: %v", unsyncedSlice, entry.Addr, err)
 				// try another node
 				continue
 			}
 
+			if err := n.importRemoteMetaSlice(ctx, entry, unsyncedSlice); err != nil {
+				log.Warnf("sync metas of slice %s from %s: %v", unsyncedSlice, entry.Addr, err)
+				continue
+			}
+
+			if err := n.importRemotePinSlice(ctx, entry, unsyncedSlice); err != nil {
+				log.Warnf("sync pins of slice %s from %s: %v", unsyncedSlice, entry.Addr, err)
+				continue
+			}
+
 			if err := syncstate.SetSlicesSynced(n.store, false, unsyncedSlice); err != nil {
 				return err
 			}
@@ -65,12 +76,12 @@ func (n *Node) syncSlices(ctx context.Context, revision int) error {
 	if len(syncedSlices) != len(unsyncedSlices) {
 		return errors.New("not full synced")
 	}
-	return n.specMgr.Tag(revision, specmgr.TagSynced)
+	return n.tagSpec(revision, specmgr.TagSynced)
 }
 
 // importRemoteBlobSlice
-func (n *Node) importRemoteBlobSlice(ctx context.Context, remoteAddr string, prefix string) (int, error) {
-	rpc := NewRPC().WithContext(ctx).WithAddr(remoteAddr)
+func (n *Node) importRemoteBlobSlice(ctx context.Context, remote spec.Entry, prefix string) (int, error) {
+	rpc := n.peerRPC(ctx, remote)
 	reader, err := rpc.GetBlobSlice(prefix)
 	if err != nil {
 		return 0, err
@@ -107,3 +118,35 @@ func (n *Node) importRemoteBlobSlice(ctx context.Context, remoteAddr string, pre
 	}
 	return nCount, nil
 }
+
+// importRemoteMetaSlice
+func (n *Node) importRemoteMetaSlice(ctx context.Context, remote spec.Entry, prefix string) error {
+	rpc := n.peerRPC(ctx, remote)
+	metas, err := rpc.GetMetaSlice(prefix)
+	if err != nil {
+		return err
+	}
+	batch := n.store.NewBatch()
+	for _, km := range metas {
+		if err := blobio.PutBlobMeta(batch, km.Key, km.Meta); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// importRemotePinSlice
+func (n *Node) importRemotePinSlice(ctx context.Context, remote spec.Entry, prefix string) error {
+	rpc := n.peerRPC(ctx, remote)
+	pins, err := rpc.GetPinSlice(prefix)
+	if err != nil {
+		return err
+	}
+	batch := n.store.NewBatch()
+	for _, pin := range pins {
+		if err := blobio.PinBlob(batch, pin.Key, pin.Label); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
@@ -8,16 +8,12 @@ import (
 	"github.com/vechain/solidb/kv"
 )
 
-const syncedSlicePrefx = ".synced-slice/"
-
-func makeKey(slice string) []byte {
-	return []byte(syncedSlicePrefx + slice)
-}
+var syncedSliceTable = kv.NewTable("synced-slice", ".synced-slice/")
 
 // SetSlicesSynced mark slices synced state.
 // If exclusive set to true, other slices will be set to unsynced.
 func SetSlicesSynced(store kv.Store, exclusive bool, slices ...string) error {
-	batch := store.NewBatch()
+	batch := syncedSliceTable.Store(store).NewBatch()
 	if exclusive {
 		synced, err := GetSyncedSlices(store)
 		if err != nil {
@@ -25,12 +21,12 @@ func SetSlicesSynced(store kv.Store, exclusive bool, slices ...string) error {
 		}
 		// delete all synced
 		for _, s := range synced {
-			batch.Delete(makeKey(s))
+			batch.Delete([]byte(s))
 		}
 	}
 
 	for _, s := range slices {
-		batch.Put(makeKey(s), []byte{})
+		batch.Put([]byte(s), []byte{})
 	}
 	return errors.Wrap(batch.Write(), "set slices synced")
 }
@@ -38,11 +34,11 @@ func SetSlicesSynced(store kv.Store, exclusive bool, slices ...string) error {
 // GetSyncedSlices returns synced slices
 func GetSyncedSlices(store kv.Store) ([]string, error) {
 	var slices []string
-	iter := store.NewIterator(kv.NewRangeWithBytesPrefix([]byte(syncedSlicePrefx)))
+	iter := syncedSliceTable.Store(store).NewIterator(nil)
 	defer iter.Release()
 	for iter.Next() {
 		key := iter.Key()
-		slices = append(slices, string(key[len(syncedSlicePrefx):]))
+		slices = append(slices, string(key))
 	}
 	if err := iter.Error(); err != nil {
 		return nil, errors.Wrap(err, "get synced slices")
@@ -1,7 +1,10 @@
 package node
 
 import (
+	"time"
+
 	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobcache"
 	"github.com/vechain/solidb/spec"
 )
 
@@ -14,9 +17,44 @@ type Revisions struct {
 
 // StatusResponse status of node
 type StatusResponse struct {
-	NodeID        string    `json:"nodeID"`
-	ClusterID     string    `json:"clusterID"`
-	SpecRevisions Revisions `json:"specRevisions"`
+	NodeID        string           `json:"nodeID"`
+	ClusterID     string           `json:"clusterID"`
+	MasterID      string           `json:"masterID"`
+	Admins        *AdminSet        `json:"admins,omitempty"`
+	SpecRevisions Revisions        `json:"specRevisions"`
+	Scrub         *ScrubStatus     `json:"scrub,omitempty"`
+	Cache         *blobcache.Stats `json:"cache,omitempty"`
+	Storage       *StorageStatus   `json:"storage,omitempty"`
+}
+
+// StorageStatus usage of store against capacity, in bytes
+type StorageStatus struct {
+	Capacity int64 `json:"capacity"`
+	Used     int64 `json:"used"`
+	Free     int64 `json:"free"`
+	// HighWatermark writes are rejected once used reaches it
+	HighWatermark int64 `json:"highWatermark"`
+	Full          bool  `json:"full"`
+}
+
+// ScrubStatus progress and findings of blob scrubbing
+type ScrubStatus struct {
+	// Rate max bytes scrubbed per second
+	Rate int `json:"rate"`
+	// Pass count of passes started
+	Pass int `json:"pass"`
+	// Cursor key of the blob last scrubbed in current pass
+	Cursor string `json:"cursor"`
+	// Scanned count of blobs scrubbed in current pass
+	Scanned int `json:"scanned"`
+	// LastCompletedAt time last pass completed
+	LastCompletedAt *time.Time `json:"lastCompletedAt,omitempty"`
+	// Corrupt count of corrupt blobs found since started
+	Corrupt int `json:"corrupt"`
+	// Repaired count of corrupt blobs repaired from replicas since started
+	Repaired int `json:"repaired"`
+	// Quarantined count of corrupt blobs pending repair
+	Quarantined int `json:"quarantined"`
 }
 
 // SyncStatusResponse sync status
@@ -28,6 +66,28 @@ type SyncStatusResponse struct {
 // InviteRequest request body struct for invitation
 type InviteRequest struct {
 	InitSpec *spec.Spec `json:"initSpec"`
+	// Rotations chain of master key rotations, from cluster ID to the inviting master
+	Rotations []KeyRotation `json:"rotations,omitempty"`
+	// Admins admin set of cluster, taken only if the node has none
+	Admins *AdminSet `json:"admins,omitempty"`
+}
+
+// SetAdminsRequest request body struct to replace admin set
+type SetAdminsRequest struct {
+	Admins AdminSet `json:"admins"`
+}
+
+// RotateMasterRequest request body struct for master key rotation
+type RotateMasterRequest struct {
+	// Rotations chain of rotations ending with the new one, which may start from any rotation recorded by node
+	Rotations []KeyRotation `json:"rotations"`
+}
+
+// RotationsResponse response body struct for chain of master key rotations
+type RotationsResponse struct {
+	ClusterID string        `json:"clusterID"`
+	MasterID  string        `json:"masterID"`
+	Rotations []KeyRotation `json:"rotations"`
 }
 
 // InviteResponse response body struct for invitation
@@ -39,3 +99,59 @@ type InviteResponse struct {
 type PutBlobResponse struct {
 	Key blob.Key `json:"key"`
 }
+
+// MaxBatchKeys max count of keys in a batch request
+const MaxBatchKeys = 1000
+
+// BatchGetRequest request body struct for batch get
+type BatchGetRequest struct {
+	Keys []blob.Key `json:"keys"`
+}
+
+// statuses of key in batch put
+const (
+	// BatchPutOK blob is written to all nodes
+	BatchPutOK = "ok"
+	// BatchPutFault blob is written with quorum, and marked to be healed
+	BatchPutFault = "fault"
+	// BatchPutFailed blob failed to be written with quorum
+	BatchPutFailed = "failed"
+)
+
+// BatchPutResult result of a key in batch put
+type BatchPutResult struct {
+	Key    blob.Key `json:"key"`
+	Status string   `json:"status"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// BatchPutResponse response body struct for batch put
+type BatchPutResponse struct {
+	Results []BatchPutResult `json:"results"`
+}
+
+// GCReachedRequest request body struct to mark blobs reachable in a GC epoch
+type GCReachedRequest struct {
+	Epoch int64      `json:"epoch"`
+	Keys  []blob.Key `json:"keys"`
+}
+
+// GCSweepRequest request body struct for GC sweep.
+// Nonce makes approvals of admins one-time, as an approved request sweeps only the epoch it's first used for.
+type GCSweepRequest struct {
+	Grace time.Duration `json:"grace"`
+	Nonce string        `json:"nonce,omitempty"`
+}
+
+// GCMarkResponse response body struct for GC mark
+type GCMarkResponse struct {
+	PinCount     int `json:"pinCount"`
+	ReachedCount int `json:"reachedCount"`
+}
+
+// GCSweepResponse response body struct for GC sweep
+type GCSweepResponse struct {
+	BlobCount      int `json:"blobCount"`
+	CandidateCount int `json:"candidateCount"`
+	DeletedCount   int `json:"deletedCount"`
+}
@@ -0,0 +1,140 @@
+// Package schema maintains version of on-disk data layout, and migrates stores of older versions.
+package schema
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/kv"
+)
+
+var (
+	schemaTable = kv.NewTable("schema", ".schema/")
+	versionKey  = []byte("version")
+	// cursorKey key to store progress of the running migration
+	cursorKey = []byte("cursor")
+)
+
+// Migration upgrades store by one version
+type Migration struct {
+	Name string
+	// Step migrate a portion of data from cursor, which is nil at beginning.
+	// Writes should be put into batch, which is committed along with the returned cursor,
+	// so that the migration can be resumed if interrupted.
+	Step func(view kv.View, batch kv.Batch, cursor []byte) (next []byte, done bool, err error)
+}
+
+// migrations ordered migrations, the i-th one upgrades store from version i to i+1.
+// Append only.
+var migrations = []Migration{
+	{"reencode legacy blobs", reencodeLegacyBlobs},
+}
+
+const reencodeBatchLen = 1000
+
+func reencodeLegacyBlobs(view kv.View, batch kv.Batch, cursor []byte) ([]byte, bool, error) {
+	var from *blob.Key
+	if cursor != nil {
+		from = &blob.Key{}
+		copy(from[:], cursor)
+	}
+	next, err := blobio.ReencodeLegacyBlobs(view, batch, from, reencodeBatchLen)
+	if err != nil {
+		return nil, false, err
+	}
+	if next == nil {
+		return nil, true, nil
+	}
+	return next[:], false, nil
+}
+
+// Version returns schema version of stores created by this binary
+func Version() int {
+	return len(migrations)
+}
+
+// GetVersion returns schema version of store, or -1 if not recorded.
+func GetVersion(store kv.Store) (int, error) {
+	value, err := schemaTable.Store(store).Get(versionKey)
+	if err != nil {
+		return 0, errors.Wrap(err, "get schema version")
+	}
+	if value.V == nil {
+		return -1, nil
+	}
+	version, err := strconv.Atoi(string(value.V))
+	if err != nil {
+		return 0, errors.Wrap(err, "get schema version")
+	}
+	return version, nil
+}
+
+func isEmpty(store kv.Store) (bool, error) {
+	iter := store.NewIterator(kv.NewRange(nil, nil))
+	defer iter.Release()
+	empty := !iter.First()
+	if err := iter.Error(); err != nil {
+		return false, err
+	}
+	return empty, nil
+}
+
+// Migrate upgrade store to the version of this binary.
+// A new store is stamped with the version, and a store without version recorded is treated as version 0.
+// Error returned if the store is newer than this binary.
+func Migrate(store kv.Store) error {
+	version, err := GetVersion(store)
+	if err != nil {
+		return err
+	}
+	if version < 0 {
+		empty, err := isEmpty(store)
+		if err != nil {
+			return errors.Wrap(err, "migrate")
+		}
+		if empty {
+			return errors.Wrap(
+				schemaTable.Store(store).Put(versionKey, []byte(strconv.Itoa(Version()))),
+				"migrate")
+		}
+		version = 0
+	}
+	if version > Version() {
+		return errors.Errorf("store schema version %d is newer than supported %d", version, Version())
+	}
+
+	table := schemaTable.Store(store)
+	for ; version < Version(); version++ {
+		m := migrations[version]
+		cursor, err := table.Get(cursorKey)
+		if err != nil {
+			return errors.Wrap(err, "migrate")
+		}
+		if cursor.V == nil {
+			log.Infof("schema: migrating to version %d, %s", version+1, m.Name)
+		} else {
+			log.Infof("schema: resuming migration to version %d, %s", version+1, m.Name)
+		}
+		for next, done := cursor.V, false; !done; {
+			batch := store.NewBatch()
+			if next, done, err = m.Step(store, batch, next); err != nil {
+				return errors.Wrapf(err, "migrate to version %d", version+1)
+			}
+			w := schemaTable.Writer(batch)
+			if done {
+				w.Delete(cursorKey)
+				w.Put(versionKey, []byte(strconv.Itoa(version+1)))
+			} else {
+				// non-nil to be distinguished from absent
+				w.Put(cursorKey, append([]byte{}, next...))
+			}
+			if err := batch.Write(); err != nil {
+				return errors.Wrapf(err, "migrate to version %d", version+1)
+			}
+		}
+	}
+	return nil
+}
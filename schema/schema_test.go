@@ -0,0 +1,44 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/blob"
+	"github.com/vechain/solidb/blobio"
+	"github.com/vechain/solidb/kv"
+	. "github.com/vechain/solidb/schema"
+)
+
+func TestMigrate(t *testing.T) {
+	assert := assert.New(t)
+
+	// new store
+	db, _ := kv.NewMemStore(kv.Options{})
+	assert.Nil(Migrate(db))
+	version, _ := GetVersion(db)
+	assert.Equal(Version(), version)
+	db.Close()
+
+	// store created before schema versioning
+	db, _ = kv.NewMemStore(kv.Options{})
+	defer db.Close()
+	data := []byte("hello world")
+	key := blob.KeyOfData(data)
+	legacyKey := append([]byte("/"), key[:]...)
+	db.Put(legacyKey, data)
+	version, _ = GetVersion(db)
+	assert.Equal(-1, version)
+
+	assert.Nil(Migrate(db))
+	version, _ = GetVersion(db)
+	assert.Equal(Version(), version)
+	value, _ := db.Get(legacyKey)
+	assert.NotEqual(data, value.V, "re-encoded")
+	b, _ := blobio.GetBlob(db, key)
+	assert.Equal(data, b.V.Data())
+
+	// newer store
+	db.Put([]byte(".schema/version"), []byte("999"))
+	assert.NotNil(Migrate(db))
+}
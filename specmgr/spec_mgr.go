@@ -19,10 +19,12 @@ const (
 	TagApproved = "approved"
 )
 const (
-	revisionPrefix = ".spec/rev/"
-	tagPrefix      = ".spec/tags/"
+	revisionPrefix = "rev/"
+	tagPrefix      = "tags/"
 )
 
+var specTable = kv.NewTable("spec", ".spec/")
+
 func makeRevisionKey(revision int) []byte {
 	return []byte(revisionPrefix + fmt.Sprintf("%010d", revision))
 }
@@ -41,7 +43,7 @@ type SpecManager struct {
 // New create a spec manager instance
 func New(store kv.Store) *SpecManager {
 	m := &SpecManager{
-		store: store,
+		store: specTable.Store(store),
 	}
 	m.taggedCache.cache = make(map[string]*spec.Spec)
 	return m
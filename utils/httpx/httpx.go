@@ -68,7 +68,18 @@ func ResponseJSON(w http.ResponseWriter, obj interface{}) error {
 	return nil
 }
 
-// HandleResponseError check response status code. If code is not 2xx, then error returned.
+// ResponseError error of non-2xx response
+type ResponseError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *ResponseError) Error() string {
+	return e.Status + ": " + e.Body
+}
+
+// HandleResponseError check response status code. If code is not 2xx, then *ResponseError returned.
 func HandleResponseError(resp *http.Response) error {
 	if resp.StatusCode/100 == 2 {
 		return nil
@@ -77,7 +88,17 @@ func HandleResponseError(resp *http.Response) error {
 	if err != nil {
 		return err
 	}
-	return errors.Errorf("%s: %s", resp.Status, string(data))
+	return &ResponseError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(data),
+	}
+}
+
+// IsResponseStatus returns whether err is *ResponseError with the status code
+func IsResponseStatus(err error, statusCode int) bool {
+	re, ok := errors.Cause(err).(*ResponseError)
+	return ok && re.StatusCode == statusCode
 }
 
 // IsCausedByContextCanceled to check if the err
@@ -0,0 +1,74 @@
+package httpx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrRangeNotSatisfiable returned by ParseRange if the range is out of content.
+var ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// ByteRange a range of content in bytes.
+type ByteRange struct {
+	Start  uint64
+	Length uint64
+}
+
+// ContentRange returns value of Content-Range header, for content of given size.
+func (r *ByteRange) ContentRange(size uint64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.Start+r.Length-1, size)
+}
+
+// ParseRange parse a single byte range from value of Range header, for content of given size.
+// Nil returned if the header is empty, invalid or has multiple ranges, which means full content should be served.
+func ParseRange(header string, size uint64) (*ByteRange, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return nil, nil
+	}
+	spec := strings.TrimSpace(header[len(prefix):])
+	if strings.Contains(spec, ",") {
+		return nil, nil
+	}
+	dash := strings.IndexByte(spec, '-')
+	if dash < 0 {
+		return nil, nil
+	}
+	startStr, endStr := strings.TrimSpace(spec[:dash]), strings.TrimSpace(spec[dash+1:])
+
+	if startStr == "" {
+		// suffix range
+		n, err := strconv.ParseUint(endStr, 10, 64)
+		if err != nil {
+			return nil, nil
+		}
+		if n == 0 || size == 0 {
+			return nil, ErrRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return &ByteRange{Start: size - n, Length: n}, nil
+	}
+
+	start, err := strconv.ParseUint(startStr, 10, 64)
+	if err != nil {
+		return nil, nil
+	}
+	if start >= size {
+		return nil, ErrRangeNotSatisfiable
+	}
+	end := size - 1
+	if endStr != "" {
+		if end, err = strconv.ParseUint(endStr, 10, 64); err != nil || end < start {
+			return nil, nil
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return &ByteRange{Start: start, Length: end - start + 1}, nil
+}
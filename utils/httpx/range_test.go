@@ -0,0 +1,38 @@
+package httpx_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vechain/solidb/utils/httpx"
+)
+
+func TestParseRange(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		header string
+		rng    *httpx.ByteRange
+		err    error
+	}{
+		{"", nil, nil},
+		{"bytes=0-9", &httpx.ByteRange{Start: 0, Length: 10}, nil},
+		{"bytes=90-", &httpx.ByteRange{Start: 90, Length: 10}, nil},
+		{"bytes=90-200", &httpx.ByteRange{Start: 90, Length: 10}, nil},
+		{"bytes=-20", &httpx.ByteRange{Start: 80, Length: 20}, nil},
+		{"bytes=-200", &httpx.ByteRange{Start: 0, Length: 100}, nil},
+		{"bytes=0-1,5-6", nil, nil},
+		{"bytes=5-1", nil, nil},
+		{"items=0-1", nil, nil},
+		{"bytes=100-", nil, httpx.ErrRangeNotSatisfiable},
+		{"bytes=-0", nil, httpx.ErrRangeNotSatisfiable},
+	}
+	for _, test := range tests {
+		rng, err := httpx.ParseRange(test.header, 100)
+		assert.Equal(test.rng, rng, test.header)
+		assert.Equal(test.err, err, test.header)
+	}
+
+	rng, _ := httpx.ParseRange("bytes=10-19", 100)
+	assert.Equal("bytes 10-19/100", rng.ContentRange(100))
+}